@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/zbindenren/cc/config"
+	"gopkg.in/yaml.v3"
+)
+
+// bumpFiles replaces the version in the version files bumps and adds them to
+// files. Paths are relative to dir. If a file is already in files, its new
+// content is updated. Several entries of the same file are applied in the
+// configured order.
+func bumpFiles(files []releaseFile, dir string, bumps []config.BumpFile, version string) ([]releaseFile, error) {
+	result := append([]releaseFile{}, files...)
+	index := map[string]int{}
+
+	for i, f := range result {
+		index[f.path] = i
+	}
+
+	for _, f := range bumps {
+		path := filepath.Join(dir, f.Path)
+
+		i, ok := index[path]
+		if !ok {
+			content, err := os.ReadFile(path) // nolint: gosec
+			if err != nil && !(os.IsNotExist(err) && f.Pattern == "" && f.Key == "") {
+				return nil, fmt.Errorf("bump file '%s': %w", f.Path, err)
+			}
+
+			i = len(result)
+			index[path] = i
+			result = append(result, releaseFile{path: path, content: content})
+		}
+
+		content, err := bump(result[i].content, f, version)
+		if err != nil {
+			return nil, fmt.Errorf("bump file '%s': %w", f.Path, err)
+		}
+
+		result[i].content = content
+	}
+
+	return result, nil
+}
+
+// bump replaces the version in content as configured by f.
+func bump(content []byte, f config.BumpFile, version string) ([]byte, error) {
+	switch {
+	case f.Pattern != "":
+		return bumpPattern(content, f.Pattern, version)
+	case f.Key != "":
+		return bumpKey(content, f.Key, version)
+	default:
+		return []byte(version + "\n"), nil
+	}
+}
+
+// bumpPattern replaces the first group of all matches of pattern with version.
+// If pattern has no group, the whole match is replaced.
+func bumpPattern(content []byte, pattern, version string) ([]byte, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := re.FindAllSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("pattern '%s' does not match", pattern)
+	}
+
+	var b bytes.Buffer
+
+	last := 0
+
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) > 2 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+
+		b.Write(content[last:start])
+		b.WriteString(version)
+
+		last = end
+	}
+
+	b.Write(content[last:])
+
+	return b.Bytes(), nil
+}
+
+// bumpKey replaces the value of the dot separated key in a YAML or JSON
+// document with version. Only the value is replaced, the formatting and
+// comments of the document are kept.
+func bumpKey(content []byte, key, version string) ([]byte, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 {
+		return nil, errors.New("empty document")
+	}
+
+	n := doc.Content[0]
+
+	for _, k := range strings.Split(key, ".") {
+		if n.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("key '%s' not found", key)
+		}
+
+		var value *yaml.Node
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == k {
+				value = n.Content[i+1]
+				break
+			}
+		}
+
+		if value == nil {
+			return nil, fmt.Errorf("key '%s' not found", key)
+		}
+
+		n = value
+	}
+
+	if n.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("value of key '%s' is not a scalar", key)
+	}
+
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	line := lines[n.Line-1]
+
+	i := bytes.Index(line[n.Column-1:], []byte(n.Value))
+	if i < 0 || n.Value == "" {
+		return nil, fmt.Errorf("value of key '%s' cannot be replaced", key)
+	}
+
+	i += n.Column - 1
+	lines[n.Line-1] = append(append(append([]byte{}, line[:i]...), version...), line[i+len(n.Value):]...)
+
+	return bytes.Join(lines, nil), nil
+}
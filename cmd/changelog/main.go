@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/zbindenren/cc/internal/cmd"
+	"github.com/zbindenren/cc/cmd"
 )
 
 // nolint: gochecknoglobals
@@ -20,8 +24,23 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// the first interrupt cancels all running git commands, a second one
+	// terminates the program immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	command := cmd.New(*b)
-	if err := command.Run(); err != nil {
+	if err := command.Run(ctx); err != nil {
+		if ctx.Err() != nil {
+			log.Fatalf("interrupted: %s", err)
+		}
+
 		log.Fatal(err)
 	}
+
+	stop()
 }
@@ -0,0 +1,451 @@
+// Package cmd creates the changelog command.
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/postfinance/flash"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+	"github.com/zbindenren/cc/internal/changelog"
+)
+
+const (
+	fileOptName           = "f"
+	debugOptName          = "d"
+	stdOutOptName         = "stdout"
+	historyOptName        = "history"
+	ignoreOptName         = "ignore"
+	sinceTagOptName       = "since"
+	initDfltConfigOptName = "init-config"
+	noPromptOptName       = "n"
+	versionOptName        = "v"
+	numOptName            = "num"
+	regenerateOptName     = "regenerate"
+	unreleasedOptName     = "unreleased"
+	promoteOptName        = "promote"
+	preOptName            = "pre"
+	buildOptName          = "build"
+	timeoutOptName        = "timeout"
+	remoteOptName         = "remote"
+	pushOptName           = "push"
+	signOptName           = "sign"
+	verifyTagOptName      = "verify-tag"
+
+	dfltChangelogFile = "CHANGELOG.md"
+	dateFormat        = "2006-01-02"
+	stableVersion     = "1.0.0"
+	dfltTimeout       = 2 * time.Minute
+)
+
+// Command represents the changelog CLI.
+type Command struct {
+	noop bool           // for tests
+	repo git.Repository // if nil the git binary is used
+	fs   *flag.FlagSet
+	b    BuildInfo
+	// flags
+	file       *string
+	debug      *bool
+	toStdOut   *bool
+	history    *bool
+	ignore     *bool
+	sinceTag   *string
+	initConfig *bool
+	noPrompt   *bool
+	version    *bool
+	num        *int
+	regenerate *string
+	unreleased *bool
+	promote    *bool
+	pre        *string
+	build      *string
+	timeout    *time.Duration
+	remote     *string
+	push       *string
+	sign       *bool
+	verifyTag  *bool
+}
+
+// Option configures a Command.
+type Option func(*Command)
+
+// WithRepository sets the git repository the command works on. By default the
+// git binary is used, or the read-only Native repository if git is not
+// installed.
+func WithRepository(r git.Repository) Option {
+	return func(c *Command) {
+		c.repo = r
+	}
+}
+
+// New creates a new Command.
+func New(b BuildInfo, opts ...Option) *Command {
+	fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+
+	c := &Command{
+		fs:         fs,
+		b:          b,
+		file:       fs.String(fileOptName, dfltChangelogFile, "changelog file name"),
+		debug:      fs.Bool(debugOptName, false, "log debug information"),
+		toStdOut:   fs.Bool(stdOutOptName, false, "output changelog to stdout instead to file"),
+		history:    fs.Bool(historyOptName, false, "create history of old versions tags (output is always stdout)"),
+		ignore:     fs.Bool(ignoreOptName, false, "ignore parsing errors of invalid (not conventional) commit messages"),
+		sinceTag:   fs.String(sinceTagOptName, "", fmt.Sprintf("in combination with -%s: if a tag is specified, the changelog will be created from that tag on", historyOptName)),
+		initConfig: fs.Bool(initDfltConfigOptName, false, fmt.Sprintf("initialize a default changelog configuration '%s'", config.FileName)),
+		noPrompt:   fs.Bool(noPromptOptName, false, "do not prompt for next version"),
+		version:    fs.Bool(versionOptName, false, "show program version information"),
+		num:        fs.Int(numOptName, 0, fmt.Sprintf("in combination with -%s: the number of tags to go back", historyOptName)),
+		regenerate: fs.String(regenerateOptName, "", "regenerate the section of the specified tag in the existing changelog file"),
+		promote:    fs.Bool(promoteOptName, false, fmt.Sprintf("release version %s regardless of the changes since the last 0.x release", stableVersion)),
+		pre:        fs.String(preOptName, "", "create a pre-release for the specified channel (i.e: rc leads to versions like 1.4.0-rc.1)"),
+		build:      fs.String(buildOptName, "", "add build metadata to the version (i.e: 20210101 leads to versions like 1.4.0+20210101)"),
+		unreleased: fs.Bool(unreleasedOptName, false, "create or update the unreleased section with all changes since the last tag (no commits and tags are created)"),
+		timeout:    fs.Duration(timeoutOptName, dfltTimeout, "timeout of a single git command (0 disables the timeout, push and fetch have no timeout unless it is set explicitly)"),
+		remote:     fs.String(remoteOptName, "", fmt.Sprintf("the remote the release is pushed to (overrides the config, default %s)", config.DefaultRemote)),
+		push:       fs.String(pushOptName, "", fmt.Sprintf("the push strategy: %s, %s, %s or %s (overrides the config, default %s)", config.PushFollowTags, config.PushExplicit, config.PushAtomic, config.PushNone, config.PushFollowTags)),
+		sign:       fs.Bool(signOptName, false, "sign the release commit and tag (uses user.signingkey and gpg.format of the git config)"),
+		verifyTag:  fs.Bool(verifyTagOptName, false, "verify the signature of the last release tag before releasing"),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Run parses flags and runs command. All git commands are canceled if ctx is
+// done.
+// nolint: gocyclo
+func (c Command) Run(ctx context.Context) error {
+	if c.fs != nil {
+		if err := c.fs.Parse(os.Args[1:]); err != nil {
+			return err
+		}
+	}
+
+	if *c.version {
+		fmt.Println(c.b.Version("changelog"))
+		return nil
+	}
+
+	// history is always written to stdout
+	if *c.history {
+		*c.toStdOut = true
+	}
+
+	l := flash.New(flash.WithDebug(*c.debug))
+
+	gitCmd := c.repo
+	if gitCmd == nil {
+		g, err := git.New(l)
+
+		switch {
+		case err == git.ErrNotFound:
+			l.Debug("git command not found - using read-only git backend")
+
+			n, err := git.Open(".", l)
+			if err != nil {
+				return err
+			}
+			defer n.Close() // nolint: errcheck
+
+			gitCmd = n
+		case err != nil:
+			return err
+		default:
+			g.Noop = c.noop
+			g.Timeout = *c.timeout
+			g.NetworkTimeout = c.networkTimeout()
+			g.NoPrompt = os.Getenv("CI") != ""
+			gitCmd = g
+		}
+	}
+
+	toplevelDir, err := gitCmd.TopLevelDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	// resolv changelog file path
+	absChangelogPath := filepath.Join(toplevelDir, *c.file)
+	c.file = &absChangelogPath
+
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	if *c.initConfig {
+		return c.runWriteConfig(l)
+	}
+
+	if !gitCmd.IsRepo(ctx) {
+		return errors.New("current folder is not a git repository")
+	}
+
+	cfg, err := config.Load(".")
+	if err != nil {
+		if err != config.ErrEmpty && err != config.ErrNotFound {
+			return err
+		}
+
+		l.Debugw("no changelog config file found - using default config", "path", filepath.Join(".", config.FileName))
+
+		cfg = &config.Default
+	}
+
+	gitCmd = gitCmd.WithTagFormat(tagFormat(cfg.Tag)).WithSigning(c.signing(*cfg))
+
+	if len(cfg.Packages) > 0 {
+		// history, unreleased and regenerate only know the root tag format
+		if *c.history || *c.unreleased || *c.regenerate != "" {
+			return fmt.Errorf("-%s, -%s and -%s are not supported if packages are configured", historyOptName, unreleasedOptName, regenerateOptName)
+		}
+
+		return c.runPackages(ctx, l, *cfg, gitCmd)
+	}
+
+	var dst io.Writer = os.Stdout
+
+	if !*c.toStdOut {
+		f, err := os.OpenFile(*c.file, os.O_CREATE|os.O_RDWR, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint: gosec
+		dst = f
+	}
+
+	if *c.history {
+		return c.runHistory(ctx, dst, l, *cfg, gitCmd)
+	}
+
+	if *c.regenerate != "" {
+		return c.runRegenerate(ctx, dst, l, *cfg, gitCmd)
+	}
+
+	if *c.unreleased {
+		return c.runUnreleased(ctx, dst, l, *cfg, gitCmd)
+	}
+
+	hasTags, err := gitCmd.HasTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !hasTags {
+		return c.runInit(ctx, dst, l, *cfg, gitCmd)
+	}
+
+	return c.runRelease(ctx, dst, l, *cfg, gitCmd)
+}
+
+func (c Command) createChangelog(cfg config.Changelog, l *flash.Logger, commits []git.Commit) (*changelog.Changelog, error) {
+	cw, err := changelog.New(changelog.WithConfig(cfg), changelog.WithLogFunc(func(msg string, keysAndValues ...interface{}) {
+		l.Debugw(msg, keysAndValues...)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range commits {
+		if strings.HasPrefix(m.Message, "Merge ") || strings.HasPrefix(m.Message, "Revert ") { // TODO: what else
+			continue
+		}
+
+		if err := cw.AddMessage(m.Revision, m.Message); err != nil && !*c.ignore {
+			return nil, err
+		}
+	}
+
+	return cw, nil
+}
+
+func (c Command) validate() error {
+	if *c.sinceTag != "" && !*c.history {
+		return fmt.Errorf("'-%s' option is only allowed in combination '-%s' option", sinceTagOptName, historyOptName)
+	}
+
+	if *c.num > 0 && !*c.history {
+		return fmt.Errorf("'-%s' option is only allowed in combination '-%s' option", numOptName, historyOptName)
+	}
+
+	if *c.regenerate != "" && *c.history {
+		return fmt.Errorf("'-%s' and '-%s' are mutually exclusive", regenerateOptName, historyOptName)
+	}
+
+	if *c.unreleased && (*c.history || *c.regenerate != "") {
+		return fmt.Errorf("'-%s' cannot be combined with '-%s' or '-%s'", unreleasedOptName, historyOptName, regenerateOptName)
+	}
+
+	releaseOnly := *c.history || *c.unreleased || *c.regenerate != ""
+
+	if *c.promote && releaseOnly {
+		return fmt.Errorf("'-%s' option is only allowed when creating a release", promoteOptName)
+	}
+
+	if *c.pre != "" && releaseOnly {
+		return fmt.Errorf("'-%s' option is only allowed when creating a release", preOptName)
+	}
+
+	if *c.build != "" && releaseOnly {
+		return fmt.Errorf("'-%s' option is only allowed when creating a release", buildOptName)
+	}
+
+	if *c.num > 0 && *c.sinceTag != "" {
+		return fmt.Errorf("'-%s' and '-%s' are mutually exclusive", numOptName, sinceTagOptName)
+	}
+
+	return config.ValidatePush(*c.push)
+}
+
+// networkTimeout returns the timeout of git commands that talk to a remote. A
+// slow push must not be killed halfway by the default timeout, so the timeout
+// only applies if the flag is set explicitly.
+func (c Command) networkTimeout() time.Duration {
+	var timeout time.Duration
+
+	if c.fs != nil {
+		c.fs.Visit(func(f *flag.Flag) {
+			if f.Name == timeoutOptName {
+				timeout = *c.timeout
+			}
+		})
+	}
+
+	return timeout
+}
+
+// remoteName returns the remote releases are pushed to. The flag overrides
+// the configuration.
+func (c Command) remoteName(cfg config.Changelog) string {
+	switch {
+	case *c.remote != "":
+		return *c.remote
+	case cfg.Remote != "":
+		return cfg.Remote
+	default:
+		return config.DefaultRemote
+	}
+}
+
+// signing returns the signing of release commits and tags. The flag enables
+// signing regardless of the configuration.
+func (c Command) signing(cfg config.Changelog) git.Signing {
+	return git.Signing{
+		Commit: cfg.Sign.Commit || *c.sign,
+		Tag:    cfg.Sign.Tag || *c.sign,
+		Key:    cfg.Sign.Key,
+	}
+}
+
+// pushStrategy returns how releases are pushed. The flag overrides the
+// configuration.
+func (c Command) pushStrategy(cfg config.Changelog) string {
+	switch {
+	case *c.push != "":
+		return *c.push
+	case cfg.Push != "":
+		return cfg.Push
+	default:
+		return config.PushFollowTags
+	}
+}
+
+func tagFormat(t config.Tag) git.TagFormat {
+	return git.TagFormat{
+		Component: t.Component,
+		Prefix:    t.Prefix,
+		OmitV:     t.OmitV,
+		Include:   t.Include,
+		Exclude:   t.Exclude,
+	}
+}
+
+// truncate removes all content after the current write position if dst is a
+// file. This is necessary if the new content is shorter than the old one.
+func truncate(dst io.Writer) error {
+	f, ok := dst.(*os.File)
+	if !ok || f == os.Stdout {
+		return nil
+	}
+
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	return f.Truncate(pos)
+}
+
+func (c Command) title(ctx context.Context, g git.Repository, tag git.Tag) (string, error) {
+	date, err := g.TagDate(ctx, tag.Name)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s (%s)", tag.Version, date), nil
+}
+
+func (c Command) confirmVersion(ctx context.Context, version semver.Version, in io.Reader, out io.Writer) (*semver.Version, error) {
+	if *c.noPrompt {
+		return &version, nil
+	}
+
+	prompt := fmt.Sprintf("create release %s (press enter to continue with this version or enter version): ", version.String())
+
+	// version prompt, with proposed version
+	fmt.Fprint(out, prompt)
+
+	userInput, err := readLine(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("reading from stdin: %w", err)
+	}
+
+	userVersion := strings.TrimSpace(userInput)
+
+	if userVersion == "" {
+		return &version, nil
+	}
+
+	v, err := semver.NewVersion(userVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid semantic version: %w", userVersion, err)
+	}
+
+	return v, nil
+}
+
+// readLine reads a line from in. It returns when ctx is done, even if
+// reading blocks.
+func readLine(ctx context.Context, in io.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+
+	ch := make(chan result, 1)
+
+	go func() {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		ch <- result{line, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-ch:
+		return r.line, r.err
+	}
+}
@@ -0,0 +1,1219 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/postfinance/flash"
+	"github.com/stretchr/testify/require"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+	"github.com/zbindenren/cc/internal/changelog"
+	"gotest.tools/assert"
+)
+
+const (
+	logOutput = false
+	windowsOS = "windows"
+)
+
+func TestInit(t *testing.T) {
+	ctx := context.Background()
+
+	c, changlogPath, cleanup := setup(t, "untagged")
+	defer cleanup()
+
+	err := c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	expectedFmt := `## 0.1.0 (%s)
+
+
+### Bug Fixes
+
+* **common**: fix an error (0fec975c9da5c5ce62f63c9d7bc0009255451006)
+  > this is the body of the message.
+  > can be multiline.
+
+
+### New Features
+
+* **common**: initial working version (c49e021712062196bff430c0acff8312dc343b74)
+* **router**: add new router flag (596ae7e44b5bfb2792d237b29159c5cc51a10a25)
+
+
+
+`
+	expected := fmt.Sprintf(expectedFmt, time.Now().Format(dateFormat))
+
+	// '\n' vs '\r\n'
+	if runtime.GOOS == windowsOS {
+		expected = strings.ReplaceAll(expected, "\n", "\r\n")
+	}
+
+	assert.Equal(t, expected, string(b))
+}
+
+func TestRelease(t *testing.T) {
+	ctx := context.Background()
+
+	c, changlogPath, cleanup := setup(t, "tagged")
+	defer cleanup()
+
+	err := c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	expected := fmt.Sprintf(expectedReleaseFmt, time.Now().Format(dateFormat))
+
+	// '\n' vs '\r\n'
+	if runtime.GOOS == windowsOS {
+		expected = strings.ReplaceAll(expected, "\n", "\r\n")
+	}
+
+	assert.Equal(t, expected, string(b))
+}
+
+func TestNetworkTimeout(t *testing.T) {
+	c := New(BuildInfo{})
+	require.NoError(t, c.fs.Parse(nil))
+	assert.Equal(t, time.Duration(0), c.networkTimeout())
+
+	c = New(BuildInfo{})
+	require.NoError(t, c.fs.Parse([]string{"-timeout", "5m"}))
+	assert.Equal(t, 5*time.Minute, c.networkTimeout())
+}
+
+func TestWithRepository(t *testing.T) {
+	m := git.NewMemory(".")
+	c := New(BuildInfo{}, WithRepository(m))
+	assert.Equal(t, git.Repository(m), c.repo)
+}
+
+func TestReleaseStdOutNative(t *testing.T) {
+	ctx := context.Background()
+
+	c, changlogPath, cleanup := setup(t, "tagged")
+	defer cleanup()
+
+	n, err := git.Open(".", flash.New())
+	require.NoError(t, err)
+
+	defer n.Close()
+
+	before, err := os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	// the lint run of the conventional commits workflow: changelog -stdout -n
+	c.repo = n
+	*c.toStdOut = true
+
+	require.NoError(t, c.Run(ctx))
+
+	after, err := os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+}
+
+func TestReleaseTagFormat(t *testing.T) {
+	ctx := context.Background()
+
+	c, changlogPath, cleanup := setup(t, "tagged")
+	defer cleanup()
+
+	g, err := git.New(flash.New())
+	require.NoError(t, err)
+
+	// replace release tag and add tags that do not match the format
+	for _, args := range [][]string{
+		{"tag", "api/v0.1.0", "v0.1.0"},
+		{"tag", "-d", "v0.1.0"},
+		{"tag", "latest"},
+		{"tag", "web/v0.3.0"},
+	} {
+		_, err = g.Run(args...)
+		require.NoError(t, err)
+	}
+
+	cfg := config.Default
+	cfg.Tag.Component = "api"
+	require.NoError(t, config.Write(".", cfg))
+
+	err = c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	expected := fmt.Sprintf(expectedReleaseFmt, time.Now().Format(dateFormat))
+
+	// '\n' vs '\r\n'
+	if runtime.GOOS == windowsOS {
+		expected = strings.ReplaceAll(expected, "\n", "\r\n")
+	}
+
+	assert.Equal(t, expected, string(b))
+}
+
+func TestUnreleased(t *testing.T) {
+	ctx := context.Background()
+
+	c, changlogPath, cleanup := setup(t, "tagged")
+	defer cleanup()
+
+	*c.unreleased = true
+
+	err := c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	expected := `## Unreleased (0.2.0)
+
+
+### Bug Fixes
+
+* **common**: fix error handling (aa5b93a9ee73be410eeab92d4276b257d15ecf6b)
+
+
+### New Features
+
+* **common**: add new feature (14f3b06858668ef50ccbcccf8266f495b434f71c)
+
+
+
+## 0.1.0 (2020-12-30)
+
+
+### Bug Fixes
+
+* **common**: fix an error (0fec975c9da5c5ce62f63c9d7bc0009255451006)
+  > this is the body of the message.
+  > can be multiline.
+
+
+### New Features
+
+* **common**: initial working version (c49e021712062196bff430c0acff8312dc343b74)
+* **router**: add new router flag (596ae7e44b5bfb2792d237b29159c5cc51a10a25)
+
+
+
+`
+
+	// '\n' vs '\r\n'
+	if runtime.GOOS == windowsOS {
+		expected = strings.ReplaceAll(expected, "\n", "\r\n")
+	}
+
+	assert.Equal(t, expected, string(b))
+
+	// a second run updates the section instead of adding a new one
+	err = c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err = os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, string(b))
+
+	// a release replaces the (committed) unreleased section
+	g, err := git.New(flash.New())
+	require.NoError(t, err)
+
+	_, err = g.Run("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-am", "docs: update unreleased changes")
+	require.NoError(t, err)
+
+	*c.unreleased = false
+
+	err = c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err = os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	expected = fmt.Sprintf(expectedReleaseFmt, time.Now().Format(dateFormat))
+
+	if runtime.GOOS == windowsOS {
+		expected = strings.ReplaceAll(expected, "\n", "\r\n")
+	}
+
+	assert.Equal(t, expected, string(b))
+}
+
+func TestReleaseMemory(t *testing.T) {
+	ctx := context.Background()
+
+	c, m, changelogPath, cleanup := setupMemory(t)
+	defer cleanup()
+
+	m.AddCommit("feat: initial version", "main.go")
+
+	// the first run creates the initial release
+	err := c.Run(ctx)
+	require.NoError(t, err)
+
+	tags, err := m.ListTags(ctx)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "v0.1.0", tags[0].Name)
+
+	fix := m.AddCommit("fix(api): handle empty body", "api.go")
+	feat := m.AddCommit("feat(api): add endpoint\n\nBREAKING CHANGE: removes old endpoint", "api.go")
+
+	err = c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(changelogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	assert.Equal(t, fmt.Sprintf("## 1.0.0 (%s)", time.Now().Format(dateFormat)), lines[0])
+	assert.Assert(t, strings.Contains(string(b), fmt.Sprintf("* **api**: handle empty body (%s)", fix)))
+	assert.Assert(t, strings.Contains(string(b), fmt.Sprintf("* **%s**:", feat)))
+	assert.Assert(t, strings.Contains(string(b), "> removes old endpoint"))
+
+	tags, err = m.ListTags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", tags[0].Name)
+	assert.Equal(t, 2, m.Pushes())
+
+	commits, err := m.Log(ctx, "", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "chore: update changelog with 1.0.0 release", commits[0].Message)
+
+	// the release tag is on the release commit
+	err = c.Run(ctx)
+	assert.ErrorContains(t, err, "no commits since last tag")
+
+	// uncommitted changes prevent a release
+	m.AddCommit("fix: another fix", "main.go")
+	m.SetUncommitted(true)
+
+	err = c.Run(ctx)
+	assert.ErrorContains(t, err, "uncommitted changes")
+}
+
+func TestReleaseRollback(t *testing.T) {
+	ctx := context.Background()
+
+	c, m, changelogPath, cleanup := setupMemory(t)
+	defer cleanup()
+
+	m.AddCommit("feat: initial version", "main.go")
+
+	// a failed initial release removes the new changelog file
+	m.Fail("push", errors.New("rejected"))
+
+	err := c.Run(ctx)
+	require.Error(t, err)
+	assert.Equal(t, fmt.Sprintf(`push: rejected
+rolled back:
+  - delete tag v0.1.0
+  - reset commit 'chore: update changelog with 0.1.0 release'
+  - unstage %[1]s
+  - restore %[1]s`, changelogPath), err.Error())
+
+	_, err = os.Stat(changelogPath)
+	assert.Assert(t, os.IsNotExist(err))
+	assert.Assert(t, !m.IsStaged(ctx, changelogPath))
+
+	hasTags, err := m.HasTags(ctx)
+	require.NoError(t, err)
+	assert.Assert(t, !hasTags)
+
+	m.Fail("push", nil)
+
+	err = c.Run(ctx)
+	require.NoError(t, err)
+
+	before, err := os.ReadFile(changelogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	// a failed release restores the changelog
+	m.AddCommit("feat: add feature", "main.go")
+	m.Fail("tag", errors.New("tag exists"))
+
+	err = c.Run(ctx)
+	require.Error(t, err)
+	assert.Equal(t, "create tag v0.2.0: tag exists\nrolled back:\n  - reset commit 'chore: update changelog with 0.2.0 release'\n  - restore "+changelogPath, err.Error())
+
+	after, err := os.ReadFile(changelogPath) // nolint: gosec
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	commits, err := m.Log(ctx, "", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "feat: add feature", commits[0].Message)
+	assert.Equal(t, 1, m.Pushes())
+}
+
+func TestReleasePreconditions(t *testing.T) {
+	var tt = []struct {
+		name     string
+		setup    func(m *git.Memory)
+		expected string
+	}{
+		{"allowed branch", func(m *git.Memory) { m.SetBranch("release/1.x") }, ""},
+		{"feature branch", func(m *git.Memory) { m.SetBranch("feature/x") }, "releases cannot be created from 'feature/x', allowed branches: main, release/*"},
+		{"detached HEAD", func(m *git.Memory) { m.SetBranch("") }, "releases cannot be created from 'detached HEAD', allowed branches: main, release/*"},
+		{"ahead", func(m *git.Memory) { m.SetSyncStatus(git.SyncStatus{Upstream: "origin/main", Ahead: 2}) }, ""},
+		{"behind", func(m *git.Memory) { m.SetSyncStatus(git.SyncStatus{Upstream: "origin/main", Behind: 1}) }, "branch 'main' is behind 'origin/main' by 1 commits, pull first"},
+		{"diverged", func(m *git.Memory) { m.SetSyncStatus(git.SyncStatus{Upstream: "origin/main", Ahead: 1, Behind: 3}) }, "branch 'main' is diverged from 'origin/main' (1 and 3 different commits), rebase or merge first"},
+		{"remote tag", func(m *git.Memory) { m.AddRemoteTag("v0.2.0") }, "tag v0.2.0 already exists on the remote"},
+		{"HEAD tagged", func(m *git.Memory) { _ = m.AddTag("v0.1.1-rc.1", "HEAD") }, ""},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			c, m, _, cleanup := setupMemory(t)
+			defer cleanup()
+
+			cfg := config.Default
+			cfg.Branches = []string{"main", "release/*"}
+			require.NoError(t, config.Write(".", cfg))
+
+			require.NoError(t, m.AddTag("v0.1.0", m.AddCommit("feat: initial version", "main.go")))
+			m.AddCommit("feat: add feature", "main.go")
+			tc.setup(m)
+
+			err := c.Run(ctx)
+			if tc.expected != "" {
+				assert.Error(t, err, tc.expected)
+				assert.Equal(t, 0, m.Pushes())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, m.Pushes())
+		})
+	}
+
+	ctx := context.Background()
+	m := git.NewMemory(".")
+
+	require.NoError(t, m.AddTag("v0.1.0", m.AddCommit("feat: initial version", "main.go")))
+	require.NoError(t, m.AddTag("v0.2.0-rc.1", m.AddCommit("feat: add feature", "main.go")))
+
+	assert.Error(t, verifyTags(ctx, "origin", []releaseTag{{g: m, version: "0.1.0"}}), "tag v0.1.0 already exists")
+	assert.Error(t, verifyTags(ctx, "origin", []releaseTag{{g: m, version: "0.2.0-rc.2"}}), "HEAD is already tagged with v0.2.0-rc.1")
+
+	// a pre-release can be promoted
+	assert.NilError(t, verifyTags(ctx, "origin", []releaseTag{{g: m, version: "0.2.0"}}))
+}
+
+func TestPushStrategies(t *testing.T) {
+	var tt = []struct {
+		strategy string
+		cfg      string
+		pushes   int
+		expected git.PushOptions
+	}{
+		{"", "", 1, git.PushOptions{Remote: "origin"}},
+		{config.PushExplicit, "", 1, git.PushOptions{Remote: "origin", Refs: []string{"HEAD:refs/heads/main", "refs/tags/v0.2.0"}}},
+		{"", config.PushAtomic, 1, git.PushOptions{Remote: "origin", Refs: []string{"HEAD:refs/heads/main", "refs/tags/v0.2.0"}, Atomic: true}},
+		{config.PushNone, "", 0, git.PushOptions{}},
+		{config.PushFollowTags, config.PushNone, 1, git.PushOptions{Remote: "origin"}},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(fmt.Sprintf("flag %s config %s", tc.strategy, tc.cfg), func(t *testing.T) {
+			ctx := context.Background()
+
+			c, m, _, cleanup := setupMemory(t)
+			defer cleanup()
+
+			cfg := config.Default
+			cfg.Push = tc.cfg
+			require.NoError(t, config.Write(".", cfg))
+			*c.push = tc.strategy
+
+			require.NoError(t, m.AddTag("v0.1.0", m.AddCommit("feat: initial version", "main.go")))
+			m.AddCommit("feat: add feature", "main.go")
+
+			require.NoError(t, c.Run(ctx))
+			assert.Equal(t, tc.pushes, m.Pushes())
+			assert.DeepEqual(t, tc.expected, m.LastPush())
+
+			hasTag, err := m.TagExists(ctx, "v0.2.0")
+			require.NoError(t, err)
+			assert.Assert(t, hasTag)
+		})
+	}
+
+	ctx := context.Background()
+
+	c, m, _, cleanup := setupMemory(t)
+	defer cleanup()
+
+	m.AddCommit("feat: initial version", "main.go")
+
+	*c.remote = "upstream"
+	assert.Error(t, c.Run(ctx), "git repo has no remote 'upstream' configured, cannot initialize changelog")
+
+	m.SetRemote("upstream")
+	require.NoError(t, c.Run(ctx))
+	assert.Equal(t, "upstream", m.LastPush().Remote)
+
+	*c.push = "force"
+	assert.Error(t, c.Run(ctx), "unsupported push strategy 'force'")
+}
+
+func TestSignedRelease(t *testing.T) {
+	ctx := context.Background()
+
+	c, m, _, cleanup := setupMemory(t)
+	defer cleanup()
+
+	cfg := config.Default
+	cfg.Sign = config.Sign{Tag: true, VerifyTag: true}
+	require.NoError(t, config.Write(".", cfg))
+
+	require.NoError(t, m.AddTag("v0.1.0", m.AddCommit("feat: initial version", "main.go")))
+	m.AddCommit("feat: add feature", "main.go")
+
+	// the unsigned last tag cannot be verified
+	err := c.Run(ctx)
+	assert.Error(t, err, "signature of tag v0.1.0: no signature found")
+	assert.Equal(t, 0, m.Pushes())
+
+	// the signed tag is verified by the next release
+	cfg.Sign.VerifyTag = false
+	require.NoError(t, config.Write(".", cfg))
+	require.NoError(t, c.Run(ctx))
+
+	commits, err := m.Log(ctx, "", "HEAD")
+	require.NoError(t, err)
+	assert.Assert(t, m.IsSigned("v0.2.0"))
+	assert.Assert(t, !m.IsSigned(commits[0].Revision))
+
+	m.AddCommit("feat: add another feature", "main.go")
+
+	*c.sign = true
+	*c.verifyTag = true
+	require.NoError(t, c.Run(ctx))
+
+	commits, err = m.Log(ctx, "", "HEAD")
+	require.NoError(t, err)
+	assert.Assert(t, m.IsSigned("v0.3.0"))
+	assert.Assert(t, m.IsSigned(commits[0].Revision))
+}
+
+func TestMessages(t *testing.T) {
+	ctx := context.Background()
+
+	c, m, _, cleanup := setupMemory(t)
+	defer cleanup()
+
+	m.AddCommit("feat: initial version", "main.go")
+	require.NoError(t, c.Run(ctx))
+
+	commits, err := m.Log(ctx, "", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "chore: update changelog with 0.1.0 release", commits[0].Message)
+	assert.Equal(t, "chore: bump version to 0.1.0", m.TagMessage("v0.1.0"))
+
+	cfg := config.Default
+	cfg.Messages = config.Messages{
+		Commit: "chore(release): {{ .Version }}",
+		Tag:    "{{ .Tag }} (previous: {{ .PreviousVersion }})\n\n{{ .Notes }}",
+	}
+	require.NoError(t, config.Write(".", cfg))
+
+	m.AddCommit("feat: add feature", "main.go")
+	require.NoError(t, c.Run(ctx))
+
+	commits, err = m.Log(ctx, "", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "chore(release): 0.2.0", commits[0].Message)
+
+	lines := strings.Split(m.TagMessage("v0.2.0"), "\n")
+	assert.Equal(t, "v0.2.0 (previous: 0.1.0)", lines[0])
+	assert.Equal(t, fmt.Sprintf("## 0.2.0 (%s)", time.Now().Format(dateFormat)), lines[2])
+	assert.Assert(t, strings.Contains(m.TagMessage("v0.2.0"), "add feature"))
+
+	// the data of a commit of several packages
+	msg := commitMessage([]releaseTag{
+		{g: m.WithTagFormat(git.TagFormat{Component: "api"}), version: "1.1.0", last: newTags("v1.0.0")[0], pkg: "api"},
+		{g: m.WithTagFormat(git.TagFormat{Component: "web"}), version: "0.1.0", pkg: "web"},
+	})
+	assert.Equal(t, "api 1.1.0, web 0.1.0", msg.Version)
+	assert.Equal(t, "api/v1.1.0", msg.Releases[0].Tag)
+	assert.Equal(t, "1.0.0", msg.Releases[0].PreviousVersion)
+
+	s, err := msg.render("commit", config.DefaultPackagesCommitMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "chore: update changelogs with api 1.1.0, web 0.1.0 release", s)
+
+	_, err = msg.render("tag", "{{ .Unknown }}")
+	assert.ErrorContains(t, err, "tag message template")
+}
+
+func TestBump(t *testing.T) {
+	var tt = []struct {
+		name     string
+		file     config.BumpFile
+		content  string
+		expected string
+	}{
+		{"whole file", config.BumpFile{}, "0.1.0\n", "1.2.3\n"},
+		{"pattern group", config.BumpFile{Pattern: `const Version = "(.*)"`}, "package main\n\nconst Version = \"0.1.0\"\n", "package main\n\nconst Version = \"1.2.3\"\n"},
+		{"pattern match", config.BumpFile{Pattern: `\d+\.\d+\.\d+`}, "v0.1.0 and v0.1.0", "v1.2.3 and v1.2.3"},
+		{
+			"yaml key",
+			config.BumpFile{Key: "appVersion"},
+			"apiVersion: v2\nversion: 0.1.0 # chart version\nappVersion: \"0.1.0\"\n",
+			"apiVersion: v2\nversion: 0.1.0 # chart version\nappVersion: \"1.2.3\"\n",
+		},
+		{
+			"json key",
+			config.BumpFile{Key: "dependencies.app"},
+			"{\n  \"version\": \"0.1.0\",\n  \"dependencies\": {\n    \"app\": \"0.1.0\"\n  }\n}\n",
+			"{\n  \"version\": \"0.1.0\",\n  \"dependencies\": {\n    \"app\": \"1.2.3\"\n  }\n}\n",
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := bump([]byte(tc.content), tc.file, "1.2.3")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(b))
+		})
+	}
+
+	_, err := bump([]byte("version: 0.1.0\n"), config.BumpFile{Key: "appVersion"}, "1.2.3")
+	assert.Error(t, err, "key 'appVersion' not found")
+
+	_, err = bump([]byte("package main\n"), config.BumpFile{Pattern: `Version = "(.*)"`}, "1.2.3")
+	assert.Error(t, err, `pattern 'Version = "(.*)"' does not match`)
+}
+
+func TestBumpFiles(t *testing.T) {
+	ctx := context.Background()
+
+	c, m, changelogPath, cleanup := setupMemory(t)
+	defer cleanup()
+
+	require.NoError(t, os.WriteFile("Chart.yaml", []byte("version: 0.1.0\nappVersion: 0.1.0\n"), 0o600))
+	m.AddCommit("feat: initial version", "Chart.yaml")
+	require.NoError(t, m.AddTag("v0.1.0", "HEAD"))
+	m.AddCommit("feat: add feature", "main.go")
+
+	cfg := config.Default
+	cfg.BumpFiles = []config.BumpFile{
+		{Path: "VERSION"},
+		{Path: "Chart.yaml", Key: "version"},
+		{Path: "Chart.yaml", Key: "appVersion"},
+	}
+	require.NoError(t, config.Write(".", cfg))
+
+	require.NoError(t, c.Run(ctx))
+
+	b, err := os.ReadFile("Chart.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "version: 0.2.0\nappVersion: 0.2.0\n", string(b))
+
+	b, err = os.ReadFile("VERSION")
+	require.NoError(t, err)
+	assert.Equal(t, "0.2.0\n", string(b))
+
+	assert.Assert(t, m.IsStaged(ctx, "VERSION"))
+	assert.Assert(t, m.IsStaged(ctx, changelogPath))
+
+	// a failed release restores the version files
+	m.AddCommit("feat: add another feature", "main.go")
+	m.Fail("push", errors.New("rejected"))
+
+	require.Error(t, c.Run(ctx))
+
+	b, err = os.ReadFile("Chart.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "version: 0.2.0\nappVersion: 0.2.0\n", string(b))
+
+	// a missing version file prevents the release
+	m.Fail("push", nil)
+	require.NoError(t, os.Remove("Chart.yaml"))
+
+	err = c.Run(ctx)
+	assert.ErrorContains(t, err, "bump file 'Chart.yaml'")
+	assert.Equal(t, 1, m.Pushes())
+}
+
+func TestPartialPush(t *testing.T) {
+	ctx := context.Background()
+
+	c, m, changelogPath, cleanup := setupMemory(t)
+	defer cleanup()
+
+	require.NoError(t, m.AddTag("v0.1.0", m.AddCommit("feat: initial version", "main.go")))
+	m.AddCommit("feat: add feature", "main.go")
+
+	// a rejected non-atomic push has not updated any ref and is rolled back
+	m.Fail("push", errors.New("rejected"))
+
+	err := c.Run(ctx)
+	require.Error(t, err)
+	assert.Equal(t, fmt.Sprintf(`push: rejected
+rolled back:
+  - delete tag v0.2.0
+  - reset commit 'chore: update changelog with 0.2.0 release'
+  - unstage %[1]s
+  - restore %[1]s`, changelogPath), err.Error())
+
+	exists, err := m.TagExists(ctx, "v0.2.0")
+	require.NoError(t, err)
+	assert.Assert(t, !exists)
+
+	// a non-atomic push that updated the branch keeps the release commit and tag
+	m.Fail("push", nil)
+	m.Fail("push-tags", errors.New("tag rejected"))
+
+	err = c.Run(ctx)
+	require.Error(t, err)
+	assert.Equal(t, `push: tag rejected
+the push has been applied in part, the release commit and tags are kept:
+  - branch main is on 'origin'
+  - tag v0.2.0 is not on 'origin'`, err.Error())
+
+	exists, err = m.TagExists(ctx, "v0.2.0")
+	require.NoError(t, err)
+	assert.Assert(t, exists)
+
+	commits, err := m.Log(ctx, "", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "chore: update changelog with 0.2.0 release", commits[0].Message)
+
+	_, err = os.Stat(changelogPath)
+	require.NoError(t, err)
+}
+
+func TestPromote(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("pre-release", func(t *testing.T) {
+		c, m, _, cleanup := setupMemory(t)
+		defer cleanup()
+
+		require.NoError(t, m.AddTag("v0.1.0", m.AddCommit("feat: initial version", "main.go")))
+		require.NoError(t, m.AddTag("v1.0.0-rc.2", m.AddCommit("feat: add feature", "main.go")))
+
+		*c.promote = true
+
+		require.NoError(t, c.Run(ctx))
+
+		tags, err := m.ListTags(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", tags[0].Name)
+	})
+
+	t.Run("no commits since the last tag", func(t *testing.T) {
+		c, m, _, cleanup := setupMemory(t)
+		defer cleanup()
+
+		require.NoError(t, m.AddTag("v0.3.0", m.AddCommit("feat: initial version", "main.go")))
+
+		*c.promote = true
+
+		require.NoError(t, c.Run(ctx))
+
+		tags, err := m.ListTags(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", tags[0].Name)
+
+		// a stable version cannot be promoted
+		err = c.Run(ctx)
+		assert.ErrorContains(t, err, "current version 1.0.0 is already stable")
+	})
+}
+
+func TestHooks(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("hooks use a POSIX shell")
+	}
+
+	ctx := context.Background()
+
+	c, m, changelogPath, cleanup := setupMemory(t)
+	defer cleanup()
+
+	docsPath := filepath.Join(filepath.Dir(changelogPath), "docs.md")
+	require.NoError(t, os.WriteFile(docsPath, []byte("0.1.0\n"), 0o600))
+
+	require.NoError(t, m.AddTag("v0.1.0", m.AddCommit("feat: initial version", "main.go", "docs.md")))
+	m.AddCommit("feat: add feature", "main.go")
+
+	// a failing pre-hook aborts the release
+	cfg := config.Default
+	cfg.Hooks.PreCommit = []string{"test -f CHANGELOG.md", "exit 3"}
+	require.NoError(t, config.Write(".", cfg))
+
+	err := c.Run(ctx)
+	assert.Error(t, err, fmt.Sprintf(`pre_commit hook: 'exit 3': exit status 3
+rolled back:
+  - unstage %[1]s
+  - restore %[1]s`, changelogPath))
+
+	_, err = os.Stat(changelogPath)
+	assert.Assert(t, os.IsNotExist(err))
+	assert.Equal(t, 0, m.Pushes())
+
+	cfg.Hooks = config.Hooks{
+		PreChangelog: []string{`echo "pre_changelog $CC_VERSION $CC_PREVIOUS_VERSION" >> hooks.log`},
+		PreCommit:    []string{`echo "pre_commit $CC_TAG $CC_PREVIOUS_TAG" >> hooks.log`, `echo "$CC_VERSION" > docs.md`},
+		PostTag:      []string{`echo "post_tag $CC_TAG" >> hooks.log`},
+		PostPush:     []string{`echo "post_push $CC_VERSION" >> hooks.log`, `cp "$CC_NOTES_FILE" notes.md`},
+	}
+	require.NoError(t, config.Write(".", cfg))
+
+	require.NoError(t, c.Run(ctx))
+
+	b, err := os.ReadFile("hooks.log")
+	require.NoError(t, err)
+	assert.Equal(t, "pre_changelog 0.2.0 0.1.0\npre_commit v0.2.0 v0.1.0\npost_tag v0.2.0\npost_push 0.2.0\n", string(b))
+
+	b, err = os.ReadFile("notes.md")
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("## 0.2.0 (%s)", time.Now().Format(dateFormat)), strings.Split(string(b), "\n")[0])
+
+	// tracked files changed by hooks are part of the release commit
+	changed, err := m.ChangedFiles(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(changed))
+
+	b, err = os.ReadFile(docsPath) // nolint: gosec
+	require.NoError(t, err)
+	assert.Equal(t, "0.2.0\n", string(b))
+
+	// and restored on rollback
+	m.AddCommit("feat: add another feature", "main.go")
+
+	cfg.Hooks = config.Hooks{
+		PreChangelog: []string{`echo "$CC_VERSION" > docs.md`},
+		PostTag:      []string{"exit 2"},
+	}
+	require.NoError(t, config.Write(".", cfg))
+
+	err = c.Run(ctx)
+	assert.Error(t, err, fmt.Sprintf(`post_tag hook: 'exit 2': exit status 2
+rolled back:
+  - delete tag v0.3.0
+  - reset commit 'chore: update changelog with 0.3.0 release'
+  - restore %s
+  - restore %s`, docsPath, changelogPath))
+
+	b, err = os.ReadFile(docsPath) // nolint: gosec
+	require.NoError(t, err)
+	assert.Equal(t, "0.2.0\n", string(b))
+
+	// a failing post-push hook does not roll back the release
+
+	cfg.Hooks = config.Hooks{PostPush: []string{"exit 1"}}
+	require.NoError(t, config.Write(".", cfg))
+
+	err = c.Run(ctx)
+	assert.Error(t, err, "post_push hook: 'exit 1': exit status 1 (the release has already been pushed)")
+
+	hasTag, err := m.TagExists(ctx, "v0.3.0")
+	require.NoError(t, err)
+	assert.Assert(t, hasTag)
+	assert.Equal(t, 2, m.Pushes())
+}
+
+func TestPackages(t *testing.T) {
+	ctx := context.Background()
+
+	c, _, cleanup := setup(t, "tagged")
+	defer cleanup()
+
+	g, err := git.New(flash.New())
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join("services", "api"), 0o750))
+	require.NoError(t, os.MkdirAll(filepath.Join("services", "web"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join("services", "api", "main.go"), []byte("package main\n"), 0o600))
+
+	cfg := config.Default
+	cfg.Packages = []config.Package{
+		{Name: "api", Path: "services/api"},
+		{Name: "web", Path: "services/web"},
+	}
+	require.NoError(t, config.Write(".", cfg))
+
+	for _, args := range [][]string{
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "feat(api): add endpoint"},
+	} {
+		_, err = g.Run(args...)
+		require.NoError(t, err)
+	}
+
+	err = c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(filepath.Join("services", "api", "CHANGELOG.md"))
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	assert.Equal(t, fmt.Sprintf("## 0.1.0 (%s)", time.Now().Format(dateFormat)), lines[0])
+	assert.Equal(t, "### New Features", lines[3])
+	assert.Assert(t, strings.HasPrefix(lines[5], "* **api**: add endpoint ("))
+	assert.Equal(t, 10, len(lines))
+
+	_, err = os.Stat(filepath.Join("services", "web", "CHANGELOG.md"))
+	assert.Assert(t, os.IsNotExist(err))
+
+	// history, unreleased and regenerate do not support packages
+	*c.unreleased = true
+
+	err = c.Run(ctx)
+	assert.ErrorContains(t, err, "not supported if packages are configured")
+}
+
+func TestRegenerate(t *testing.T) {
+	ctx := context.Background()
+
+	c, changlogPath, cleanup := setup(t, "tagged")
+	defer cleanup()
+
+	*c.regenerate = "v0.1.0"
+
+	old := `# Changelog
+
+manually added notes.
+
+## 0.1.0 (2020-12-30)
+
+outdated section
+
+
+## 0.0.1 (2020-12-01)
+
+* manually edited entry
+`
+	if runtime.GOOS == windowsOS {
+		old = strings.ReplaceAll(old, "\n", "\r\n")
+	}
+
+	err := os.WriteFile(changlogPath, []byte(old), 0o600)
+	require.NoError(t, err)
+
+	err = c.Run(ctx)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(changlogPath) // nolint: gosec
+	require.NoError(t, err)
+
+	expected := `# Changelog
+
+manually added notes.
+
+## 0.1.0 (2020-12-30)
+
+
+### Bug Fixes
+
+* **common**: fix an error (0fec975c9da5c5ce62f63c9d7bc0009255451006)
+  > this is the body of the message.
+  > can be multiline.
+
+
+### New Features
+
+* **common**: initial working version (c49e021712062196bff430c0acff8312dc343b74)
+* **router**: add new router flag (596ae7e44b5bfb2792d237b29159c5cc51a10a25)
+
+
+
+## 0.0.1 (2020-12-01)
+
+* manually edited entry
+`
+
+	// '\n' vs '\r\n'
+	if runtime.GOOS == windowsOS {
+		expected = strings.ReplaceAll(expected, "\n", "\r\n")
+	}
+
+	assert.Equal(t, expected, string(b))
+}
+
+const expectedReleaseFmt = `## 0.2.0 (%s)
+
+
+### Bug Fixes
+
+* **common**: fix error handling (aa5b93a9ee73be410eeab92d4276b257d15ecf6b)
+
+
+### New Features
+
+* **common**: add new feature (14f3b06858668ef50ccbcccf8266f495b434f71c)
+
+
+
+## 0.1.0 (2020-12-30)
+
+
+### Bug Fixes
+
+* **common**: fix an error (0fec975c9da5c5ce62f63c9d7bc0009255451006)
+  > this is the body of the message.
+  > can be multiline.
+
+
+### New Features
+
+* **common**: initial working version (c49e021712062196bff430c0acff8312dc343b74)
+* **router**: add new router flag (596ae7e44b5bfb2792d237b29159c5cc51a10a25)
+
+
+
+`
+
+func TestNextVersion(t *testing.T) {
+	var tt = []struct {
+		current     string
+		releaseType changelog.ReleaseType
+		zeroMajor   string
+		expected    string
+	}{
+		{"1.2.3", changelog.Major, "", "2.0.0"},
+		{"1.2.3", changelog.Minor, config.ZeroMajorShift, "1.3.0"},
+		{"1.2.3", changelog.None, "", "1.2.3"},
+		{"0.2.3", changelog.Major, "", "1.0.0"},
+		{"0.2.3", changelog.Major, config.ZeroMajorStrict, "1.0.0"},
+		{"0.2.3", changelog.Major, config.ZeroMajorShift, "0.3.0"},
+		{"0.2.3", changelog.Minor, config.ZeroMajorShift, "0.2.4"},
+		{"0.2.3", changelog.Patch, config.ZeroMajorShift, "0.2.4"},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.current+" "+tc.zeroMajor, func(t *testing.T) {
+			next := nextVersion(semver.MustParse(tc.current), tc.releaseType, tc.zeroMajor)
+			assert.Equal(t, tc.expected, next.String())
+		})
+	}
+}
+
+func TestReleaseVersion(t *testing.T) {
+	var tt = []struct {
+		name        string
+		tags        git.Tags
+		releaseType changelog.ReleaseType
+		pre         string
+		build       string
+		expected    string
+	}{
+		{"release", newTags("v1.3.0"), changelog.Minor, "", "", "1.4.0"},
+		{"nothing to release", newTags("v1.3.0"), changelog.None, "", "", ""},
+		{"first pre-release", newTags("v1.3.0"), changelog.Minor, "rc", "", "1.4.0-rc.1"},
+		{"next pre-release", newTags("v1.4.0-rc.2", "v1.4.0-rc.1", "v1.3.0"), changelog.Minor, "rc", "", "1.4.0-rc.3"},
+		{"other channel", newTags("v1.4.0-rc.2", "v1.3.0"), changelog.Minor, "beta", "", "1.4.0-beta.1"},
+		{"promote pre-release", newTags("v1.4.0-rc.2", "v1.4.0-rc.1", "v1.3.0"), changelog.Patch, "", "", "1.4.0"},
+		{"pre-release with breaking change", newTags("v1.4.0-rc.1", "v1.3.0"), changelog.Major, "rc", "", "2.0.0-rc.1"},
+		{"only pre-releases", newTags("v1.0.0-rc.1"), changelog.Patch, "", "", "1.0.0"},
+		{"build metadata", newTags("v1.3.0"), changelog.Patch, "", "20210101", "1.3.1+20210101"},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			c := Command{
+				promote: newBoolPtr(false),
+				pre:     newStrPtr(tc.pre),
+				build:   newStrPtr(tc.build),
+			}
+
+			v, err := c.releaseVersion(tc.tags, tc.tags, tc.releaseType, config.Default)
+			require.NoError(t, err)
+
+			if tc.expected == "" {
+				assert.Assert(t, v == nil)
+				return
+			}
+
+			assert.Equal(t, tc.expected, v.String())
+		})
+	}
+}
+
+func TestConfirmVersion(t *testing.T) {
+	ctx := context.Background()
+	c := newCommand()
+	*c.noPrompt = false
+
+	v, err := c.confirmVersion(ctx, *semver.MustParse("1.2.0"), strings.NewReader("\n"), ioutil.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.0", v.String())
+
+	v, err = c.confirmVersion(ctx, *semver.MustParse("1.2.0"), strings.NewReader("2.0.0\n"), ioutil.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", v.String())
+
+	// an interrupt cancels a blocking prompt
+	r, w := io.Pipe()
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = c.confirmVersion(ctx, *semver.MustParse("1.2.0"), r, ioutil.Discard)
+	assert.Assert(t, errors.Is(err, context.Canceled))
+}
+
+func TestPreviousTag(t *testing.T) {
+	tags := newTags("v1.4.0", "v1.4.0-rc.2", "v1.4.0-rc.1", "v1.3.0")
+
+	assert.Equal(t, "v1.3.0", previousTag(tags, 0).Name)
+	assert.Equal(t, "v1.4.0-rc.1", previousTag(tags, 1).Name)
+	assert.Equal(t, "v1.3.0", previousTag(tags, 2).Name)
+	assert.Equal(t, "", previousTag(tags, 3).Name)
+}
+
+func setup(t *testing.T, repoName string) (c Command, changelogPath string, cleanup func()) {
+	tmp, err := ioutil.TempDir("", repoName)
+	require.NoError(t, err)
+
+	old, err := os.Getwd()
+	require.NoError(t, err)
+
+	repoDir := filepath.Join(tmp, repoName)
+
+	g, err := git.New(flash.New())
+	require.NoError(t, err)
+	_, err = g.Run("clone", pathToBundle(repoName), repoDir)
+	require.NoError(t, err)
+
+	err = os.Chdir(repoDir)
+	require.NoError(t, err)
+
+	c = newCommand()
+
+	cleanup = func() {
+		os.RemoveAll(tmp)
+
+		if err := os.Chdir(old); err != nil {
+			panic(err)
+		}
+	}
+
+	return c, filepath.Join(tmp, repoName, dfltChangelogFile), cleanup
+}
+
+// setupMemory creates a command with an empty in-memory repository in a
+// temporary directory.
+func setupMemory(t *testing.T) (c Command, m *git.Memory, changelogPath string, cleanup func()) {
+	tmp, err := ioutil.TempDir("", "memory")
+	require.NoError(t, err)
+
+	old, err := os.Getwd()
+	require.NoError(t, err)
+
+	err = os.Chdir(tmp)
+	require.NoError(t, err)
+
+	m = git.NewMemory(tmp)
+	c = newCommand()
+	c.repo = m
+
+	cleanup = func() {
+		os.RemoveAll(tmp)
+
+		if err := os.Chdir(old); err != nil {
+			panic(err)
+		}
+	}
+
+	return c, m, filepath.Join(tmp, dfltChangelogFile), cleanup
+}
+
+func newCommand() Command {
+	return Command{
+		noop:       true,
+		debug:      newBoolPtr(logOutput),
+		initConfig: newBoolPtr(false),
+		history:    newBoolPtr(false),
+		noPrompt:   newBoolPtr(true),
+		toStdOut:   newBoolPtr(false),
+		file:       newStrPtr(dfltChangelogFile),
+		version:    newBoolPtr(false),
+		num:        newIntPtr(0),
+		sinceTag:   newStrPtr(""),
+		regenerate: newStrPtr(""),
+		unreleased: newBoolPtr(false),
+		promote:    newBoolPtr(false),
+		pre:        newStrPtr(""),
+		build:      newStrPtr(""),
+		timeout:    newDurationPtr(dfltTimeout),
+		remote:     newStrPtr(""),
+		push:       newStrPtr(""),
+		sign:       newBoolPtr(false),
+		verifyTag:  newBoolPtr(false),
+	}
+}
+
+func newTags(names ...string) git.Tags {
+	tags := git.Tags{}
+
+	for _, n := range names {
+		t, ok := git.TagFormat{}.Parse(n)
+		if !ok {
+			panic("invalid tag " + n)
+		}
+
+		tags = append(tags, t)
+	}
+
+	return tags
+}
+
+func newBoolPtr(b bool) *bool {
+	return &b
+}
+
+func newIntPtr(i int) *int {
+	return &i
+}
+
+func newStrPtr(s string) *string {
+	return &s
+}
+
+func newDurationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+func pathToBundle(name string) string {
+	_, filename, _, _ := runtime.Caller(0) // nolint: dogsled
+	dir := filepath.Dir(filename)
+
+	return filepath.Join(dir, "test", name+".bundle")
+}
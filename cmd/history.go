@@ -1,16 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 
 	"github.com/postfinance/flash"
 	"github.com/zbindenren/cc/config"
-	"github.com/zbindenren/cc/internal/git"
+	"github.com/zbindenren/cc/git"
 )
 
-func (c Command) runHistory(dst io.Writer, l *flash.Logger, cfg config.Changelog, g *git.Command) error {
-	tags, err := g.ListTags()
+func (c Command) runHistory(ctx context.Context, dst io.Writer, l *flash.Logger, cfg config.Changelog, g git.Repository) error {
+	tags, err := g.ListTags(ctx)
 	if err != nil {
 		return err
 	}
@@ -30,25 +31,19 @@ func (c Command) runHistory(dst io.Writer, l *flash.Logger, cfg config.Changelog
 	}
 
 	for i := 0; i <= max; i++ {
-		var start string
+		start, end := previousTag(tags, i), tags[i]
 
-		end := tags[i]
-
-		if i+1 < len(tags) {
-			start = tags[i+1]
-		}
-
-		revs, err := g.RevList(start, end)
+		commits, err := g.Log(ctx, start.Name, end.Name)
 		if err != nil {
 			return err
 		}
 
-		cw, err := c.createChangelog(g, cfg, l, revs)
+		cw, err := c.createChangelog(cfg, l, commits)
 		if err != nil {
 			return err
 		}
 
-		title, err := c.title(g, tags[i])
+		title, err := c.title(ctx, g, tags[i])
 		if err != nil {
 			return err
 		}
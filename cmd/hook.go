@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/postfinance/flash"
+	"github.com/zbindenren/cc/git"
+)
+
+// hookRunner runs the hook commands of a release. Each command runs once for
+// every release tag with the release data in environment variables.
+type hookRunner struct {
+	l     *flash.Logger
+	dir   string       // the working directory of the commands
+	tags  []releaseTag // the release tags
+	notes []string     // the files with the release notes of each tag
+}
+
+// newHookRunner writes the release notes of tags to temporary files. The
+// files are removed by close.
+func newHookRunner(l *flash.Logger, dir string, tags []releaseTag) (*hookRunner, error) {
+	h := &hookRunner{l: l, dir: dir, tags: tags}
+
+	for _, t := range tags {
+		f, err := os.CreateTemp("", "release-notes-*.md")
+		if err != nil {
+			h.close()
+			return nil, err
+		}
+
+		h.notes = append(h.notes, f.Name())
+
+		_, err = f.Write(t.notes)
+		if e := f.Close(); err == nil {
+			err = e
+		}
+
+		if err != nil {
+			h.close()
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// run runs the commands of the hook name.
+func (h *hookRunner) run(ctx context.Context, name string, commands []string) error {
+	for i, t := range h.tags {
+		env := h.env(t, h.notes[i])
+
+		for _, command := range commands {
+			h.l.Debugw("run hook", "hook", name, "cmd", command, "tag", t.g.TagName(t.version))
+
+			cmd := shell(ctx, command)
+			cmd.Dir = h.dir
+			cmd.Env = append(os.Environ(), env...)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("'%s': %w", command, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// env returns the environment variables with the data of the release tag t.
+func (h *hookRunner) env(t releaseTag, notesFile string) []string {
+	m := tagMessage(t)
+
+	return []string{
+		"CC_PACKAGE=" + m.Package,
+		"CC_VERSION=" + m.Version,
+		"CC_PREVIOUS_VERSION=" + m.PreviousVersion,
+		"CC_TAG=" + m.Tag,
+		"CC_PREVIOUS_TAG=" + m.PreviousTag,
+		"CC_NOTES_FILE=" + notesFile,
+	}
+}
+
+// close removes the release notes files.
+func (h *hookRunner) close() {
+	for _, n := range h.notes {
+		if err := os.Remove(n); err != nil {
+			h.l.Debugw("remove release notes", "file", n, "err", err)
+		}
+	}
+}
+
+// stageHookChanges stages the tracked files changed by hooks, so that they
+// are part of the release commit. On rollback, they are restored from HEAD.
+// The staged files are added to paths, which are the files already part of
+// the release commit.
+func stageHookChanges(ctx context.Context, tx *transaction, g git.Repository, dir string, paths []string) ([]string, error) {
+	changed, err := g.ChangedFiles(ctx)
+	if err != nil {
+		return nil, tx.rollback(fmt.Errorf("list files changed by hooks: %w", err))
+	}
+
+	release := map[string]bool{}
+
+	for _, p := range paths {
+		release[p] = true
+	}
+
+	for _, f := range changed {
+		p := filepath.Join(dir, f)
+		if release[p] {
+			continue
+		}
+
+		err := tx.run("stage "+p, func() error {
+			return g.StageFile(ctx, p)
+		}, "restore "+p, func(ctx context.Context) error {
+			return g.RestoreFile(ctx, p)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, p)
+	}
+
+	return paths, nil
+}
+
+// shell returns the command that runs command with the shell of the
+// operating system.
+func shell(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command) // nolint: gosec
+	}
+
+	return exec.CommandContext(ctx, "sh", "-c", command) // nolint: gosec
+}
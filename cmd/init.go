@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/postfinance/flash"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+)
+
+const initialVersion = "v0.1.0"
+
+func (c Command) runInit(ctx context.Context, dst io.Writer, l *flash.Logger, cfg config.Changelog, g git.Repository) error {
+	if err := c.verifyRepo(ctx, cfg, g); err != nil {
+		return err
+	}
+
+	next, _ := semver.NewVersion(initialVersion)
+	if *c.promote {
+		next = semver.MustParse(stableVersion)
+	}
+
+	first, err := c.applyIdentifiers(*next, nil)
+	if err != nil {
+		return err
+	}
+
+	next = &first
+
+	commits, err := g.Log(ctx, "", "HEAD")
+	if err != nil {
+		return err
+	}
+
+	cw, err := c.createChangelog(cfg, l, commits)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("create first version: %s\n", next)
+
+	version, err := c.confirmVersion(ctx, *next, os.Stdin, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("%s (%s)", version, time.Now().Format(dateFormat))
+
+	var section bytes.Buffer
+
+	cw.Write(title, &section)
+
+	if *c.toStdOut {
+		_, err := dst.Write(section.Bytes())
+		return err
+	}
+
+	toplevelDir, err := g.TopLevelDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	files, err := bumpFiles([]releaseFile{{path: *c.file, content: section.Bytes()}}, toplevelDir, cfg.BumpFiles, version.String())
+	if err != nil {
+		return err
+	}
+
+	return c.publish(ctx, l, cfg, g, files,
+		[]releaseTag{{g: g, version: version.String(), notes: section.Bytes()}},
+	)
+}
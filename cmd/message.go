@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// message is passed to the templates of the release commit message and the
+// tag annotation.
+type message struct {
+	Package         string    // the package name, empty if no packages are configured
+	Version         string    // the new version, for a commit of several packages '<package> <version>, ...'
+	PreviousVersion string    // the last version, empty for the first release
+	Tag             string    // the new release tag, empty for a commit of several packages
+	PreviousTag     string    // the last release tag, empty for the first release
+	Notes           string    // the rendered changelog section
+	Releases        []message // the releases of all packages, only set for a commit of several packages
+}
+
+// tagMessage returns the message data of the release tag t.
+func tagMessage(t releaseTag) message {
+	m := message{
+		Package:     t.pkg,
+		Version:     t.version,
+		Tag:         t.g.TagName(t.version),
+		PreviousTag: t.last.Name,
+		Notes:       string(t.notes),
+	}
+
+	if t.last.Version != nil {
+		m.PreviousVersion = t.last.Version.String()
+	}
+
+	return m
+}
+
+// commitMessage returns the message data of the commit of the release tags.
+// For a single release it is the same as the message data of the tag.
+func commitMessage(tags []releaseTag) message {
+	if len(tags) == 1 && tags[0].pkg == "" {
+		return tagMessage(tags[0])
+	}
+
+	m := message{}
+	versions := make([]string, 0, len(tags))
+	notes := make([]string, 0, len(tags))
+
+	for _, t := range tags {
+		r := tagMessage(t)
+		m.Releases = append(m.Releases, r)
+		versions = append(versions, fmt.Sprintf("%s %s", r.Package, r.Version))
+		notes = append(notes, r.Notes)
+	}
+
+	m.Version = strings.Join(versions, ", ")
+	m.Notes = strings.Join(notes, "")
+
+	return m
+}
+
+// render executes the template text with m. Leading and trailing white space
+// is removed from the result.
+func (m message) render(name, text string) (string, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s message template: %w", name, err)
+	}
+
+	var b strings.Builder
+
+	if err := t.Execute(&b, m); err != nil {
+		return "", fmt.Errorf("%s message template: %w", name, err)
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/postfinance/flash"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+)
+
+// packageRelease is the release of a single monorepo package.
+type packageRelease struct {
+	name    string
+	file    string
+	g       git.Repository
+	last    git.Tag
+	version *semver.Version
+	section []byte
+	bump    []config.BumpFile
+}
+
+// runPackages creates a release for each configured package with changes
+// since its last release. All changelogs are committed in one commit.
+// nolint: gocyclo,funlen
+func (c Command) runPackages(ctx context.Context, l *flash.Logger, cfg config.Changelog, g git.Repository) error {
+	if err := c.verifyRepo(ctx, cfg, g); err != nil {
+		return err
+	}
+
+	toplevelDir, err := g.TopLevelDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	releases := []packageRelease{}
+	summary := []string{}
+
+	for _, p := range cfg.Packages {
+		r, err := c.preparePackage(ctx, l, cfg.Package(p), g, p, toplevelDir)
+		if err != nil {
+			return fmt.Errorf("package '%s': %w", p.Name, err)
+		}
+
+		if r == nil {
+			summary = append(summary, fmt.Sprintf("%s: nothing to release", p.Name))
+			continue
+		}
+
+		releases = append(releases, *r)
+		summary = append(summary, fmt.Sprintf("%s: %s -> %s", p.Name, versionOrNone(r.last), r.version))
+	}
+
+	if len(releases) == 0 {
+		fmt.Println(strings.Join(summary, "\n"))
+		return nil
+	}
+
+	if *c.toStdOut {
+		for _, r := range releases {
+			if _, err := os.Stdout.Write(r.section); err != nil {
+				return err
+			}
+		}
+
+		fmt.Println(strings.Join(summary, "\n"))
+
+		return nil
+	}
+
+	files := make([]releaseFile, 0, len(releases))
+	tags := make([]releaseTag, 0, len(releases))
+
+	for _, r := range releases {
+		old, err := os.ReadFile(r.file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		// the unreleased section is replaced by the new release section
+		old, _ = replaceSection(old, nil, cfg.Localized().Unreleased)
+
+		l.Debugw("update changelog", "package", r.name, "file", r.file, "version", r.version)
+
+		files = append(files, releaseFile{path: r.file, content: append(r.section, old...)})
+
+		files, err = bumpFiles(files, toplevelDir, r.bump, r.version.String())
+		if err != nil {
+			return fmt.Errorf("package '%s': %w", r.name, err)
+		}
+
+		tags = append(tags, releaseTag{g: r.g, version: r.version.String(), last: r.last, pkg: r.name, notes: r.section})
+	}
+
+	if err := c.publish(ctx, l, cfg, g, files, tags); err != nil {
+		return err
+	}
+
+	fmt.Println(strings.Join(summary, "\n"))
+
+	return nil
+}
+
+// preparePackage prepares the release of a package. If there is nothing to
+// release, nil is returned.
+func (c Command) preparePackage(ctx context.Context, l *flash.Logger, cfg config.Changelog, g git.Repository, p config.Package, toplevelDir string) (*packageRelease, error) {
+	g = g.WithTagFormat(tagFormat(cfg.Tag))
+
+	r, err := c.prepareRelease(ctx, l, cfg, g, filepath.Join(toplevelDir, p.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cw == nil || r.next == nil {
+		return nil, nil
+	}
+
+	fmt.Printf("package %s - last version: %s\n", p.Name, versionOrNone(r.last))
+	fmt.Printf("package %s - next version: %s\n", p.Name, r.next)
+
+	version, err := c.confirmVersion(ctx, *r.next, os.Stdin, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.last.Name != "" && !version.GreaterThan(r.last.Version) {
+		return nil, fmt.Errorf("version must be greater than current version %s", r.last.Version)
+	}
+
+	var section bytes.Buffer
+
+	r.cw.Write(fmt.Sprintf("%s (%s)", version, time.Now().Format(dateFormat)), &section)
+
+	return &packageRelease{
+		name:    p.Name,
+		file:    filepath.Join(toplevelDir, p.ChangelogFile()),
+		g:       g,
+		last:    r.last,
+		version: version,
+		section: section.Bytes(),
+		bump:    p.BumpFiles,
+	}, nil
+}
+
+// versionOrNone returns the version of tag or 'none' for an empty tag.
+func versionOrNone(tag git.Tag) string {
+	if tag.Name == "" {
+		return "none"
+	}
+
+	return tag.Version.String()
+}
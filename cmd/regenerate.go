@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/postfinance/flash"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+)
+
+func (c Command) runRegenerate(ctx context.Context, dst io.Writer, l *flash.Logger, cfg config.Changelog, g git.Repository) error {
+	tags, err := g.ListTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	i := tags.Index(*c.regenerate)
+	if i < 0 {
+		return fmt.Errorf("tag '%s' not found", *c.regenerate)
+	}
+
+	start := previousTag(tags, i)
+
+	commits, err := g.Log(ctx, start.Name, tags[i].Name)
+	if err != nil {
+		return err
+	}
+
+	cw, err := c.createChangelog(cfg, l, commits)
+	if err != nil {
+		return err
+	}
+
+	title, err := c.title(ctx, g, tags[i])
+	if err != nil {
+		return err
+	}
+
+	var section bytes.Buffer
+
+	cw.Write(title, &section)
+
+	if *c.toStdOut {
+		_, err := dst.Write(section.Bytes())
+		return err
+	}
+
+	old, err := os.ReadFile(*c.file)
+	if err != nil {
+		return err
+	}
+
+	updated, ok := replaceSection(old, section.Bytes(), tags[i].Version.String()+" ")
+	if !ok {
+		return fmt.Errorf("no section for tag '%s' found in %s", tags[i].Name, *c.file)
+	}
+
+	l.Debugw("regenerate changelog section", "file", *c.file, "title", title)
+
+	if _, err := dst.Write(updated); err != nil {
+		return err
+	}
+
+	return truncate(dst)
+}
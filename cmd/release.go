@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/postfinance/flash"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+	"github.com/zbindenren/cc/internal/changelog"
+)
+
+// nolint: gocyclo,funlen
+func (c Command) runRelease(ctx context.Context, dst io.Writer, l *flash.Logger, cfg config.Changelog, g git.Repository) error {
+	if err := c.verifyRepo(ctx, cfg, g); err != nil {
+		return err
+	}
+
+	r, err := c.prepareRelease(ctx, l, cfg, g)
+	if err != nil {
+		return err
+	}
+
+	if r.last.Name == "" {
+		return errors.New("no tag found in the history of HEAD")
+	}
+
+	if r.cw == nil {
+		return errors.New("no commits since last tag")
+	}
+
+	if r.next == nil {
+		fmt.Printf("nothing to release since %s\n", r.last.Name)
+		return nil
+	}
+
+	cw, current, next := r.cw, r.last.Version, r.next
+
+	fmt.Printf("last version: %s\n", current)
+	fmt.Printf("next version: %s\n", next)
+
+	version, err := c.confirmVersion(ctx, *next, os.Stdin, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if !version.GreaterThan(current) {
+		return fmt.Errorf("version must be greater than current version %s", current)
+	}
+
+	title := fmt.Sprintf("%s (%s)", version, time.Now().Format(dateFormat))
+
+	old, err := os.ReadFile(*c.file)
+	if err != nil && !*c.toStdOut {
+		return err
+	}
+
+	// the unreleased section is replaced by the new release section
+	old, _ = replaceSection(old, nil, cfg.Localized().Unreleased)
+
+	l.Debugw("update changelog", "file", *c.file, "title", title)
+
+	var section bytes.Buffer
+
+	cw.Write(title, &section)
+
+	if *c.toStdOut {
+		_, err := dst.Write(section.Bytes())
+		return err
+	}
+
+	toplevelDir, err := g.TopLevelDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	files, err := bumpFiles([]releaseFile{{path: *c.file, content: append(section.Bytes(), old...)}}, toplevelDir, cfg.BumpFiles, version.String())
+	if err != nil {
+		return err
+	}
+
+	return c.publish(ctx, l, cfg, g, files,
+		[]releaseTag{{g: g, version: version.String(), last: r.last, notes: section.Bytes()}},
+	)
+}
+
+// release is a prepared release.
+type release struct {
+	last git.Tag              // the last tag, empty if there is no tag
+	next *semver.Version      // the proposed version, nil if there is nothing to release
+	cw   *changelog.Changelog // the changes, nil if there are no commits since the last tag
+}
+
+// prepareRelease collects the changes since the last tag and computes the
+// version of the next release. If paths are given, only commits touching these
+// paths are considered.
+// nolint: gocyclo
+func (c Command) prepareRelease(ctx context.Context, l *flash.Logger, cfg config.Changelog, g git.Repository, paths ...string) (*release, error) {
+	tags, err := g.MergedTags(ctx, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	r := release{}
+
+	var stable git.Tag
+
+	if len(tags) > 0 {
+		r.last = tags[0]
+		stable = lastStable(tags)
+	}
+
+	// a pre-release contains the changes since the last tag, a release all
+	// changes since the last release
+	start := r.last
+	if *c.pre == "" {
+		start = stable
+	}
+
+	commits, err := g.Log(ctx, tagRev(start), "HEAD", paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	// a version can be promoted without new commits
+	if len(commits) == 0 && !*c.promote {
+		return &r, nil
+	}
+
+	r.cw, err = c.createChangelog(cfg, l, commits)
+	if err != nil {
+		return nil, err
+	}
+
+	// the version increase depends on all changes since the last release
+	releaseType := r.cw.ReleaseType()
+
+	if start.Name != stable.Name {
+		stableCommits, err := g.Log(ctx, tagRev(stable), "HEAD", paths...)
+		if err != nil {
+			return nil, err
+		}
+
+		sinceStable, err := c.createChangelog(cfg, l, stableCommits)
+		if err != nil {
+			return nil, err
+		}
+
+		releaseType = sinceStable.ReleaseType()
+	}
+
+	allTags, err := g.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tags) == 0 {
+		first := *semver.MustParse(initialVersion)
+		if *c.promote {
+			first = *semver.MustParse(stableVersion)
+		}
+
+		first, err = c.applyIdentifiers(first, allTags)
+		if err != nil {
+			return nil, err
+		}
+
+		r.next = &first
+
+		return &r, nil
+	}
+
+	r.next, err = c.releaseVersion(tags, allTags, releaseType, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// verifyRepo verifies that the repository is ready for a release: the remote
+// is configured, there are no uncommitted changes and the current branch is
+// an allowed release branch that is neither behind nor diverged from its
+// upstream branch. If the changelog is only printed, nothing is verified.
+func (c Command) verifyRepo(ctx context.Context, cfg config.Changelog, g git.Repository) error {
+	if *c.toStdOut {
+		return nil
+	}
+
+	if remote := c.remoteName(cfg); !g.HasRemote(ctx, remote) {
+		return fmt.Errorf("git repo has no remote '%s' configured, cannot initialize changelog", remote)
+	}
+
+	uncommmited, err := g.HasUncommitted(ctx)
+	if err != nil {
+		return err
+	}
+
+	if uncommmited {
+		return errors.New("git repository contains uncommitted changes")
+	}
+
+	branch, err := g.CurrentBranch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.IsAllowedBranch(branch) {
+		if branch == "" {
+			branch = "detached HEAD"
+		}
+
+		return fmt.Errorf("releases cannot be created from '%s', allowed branches: %s", branch, strings.Join(cfg.Branches, ", "))
+	}
+
+	s, err := g.SyncStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	l := fmt.Sprintf("branch '%s' is", branch)
+
+	switch {
+	case s.Ahead > 0 && s.Behind > 0:
+		return fmt.Errorf("%s diverged from '%s' (%d and %d different commits), rebase or merge first", l, s.Upstream, s.Ahead, s.Behind)
+	case s.Behind > 0:
+		return fmt.Errorf("%s behind '%s' by %d commits, pull first", l, s.Upstream, s.Behind)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+)
+
+const sectionHeading = "## "
+
+// replaceSection replaces the release section of changelog whose heading starts
+// with prefix by section. Everything before and after the replaced section is
+// kept untouched. If no matching section is found, ok is false.
+func replaceSection(changelog, section []byte, prefix string) (result []byte, ok bool) {
+	start, end := findSection(changelog, prefix)
+	if start < 0 {
+		return changelog, false
+	}
+
+	result = make([]byte, 0, len(changelog)-(end-start)+len(section))
+	result = append(result, changelog[:start]...)
+	result = append(result, section...)
+	result = append(result, changelog[end:]...)
+
+	return result, true
+}
+
+// findSection returns the start and end offsets of the release section whose
+// heading starts with prefix. A release section ends where the next release
+// heading starts or at the end of the changelog. If no section is found, -1 is
+// returned for both offsets.
+func findSection(changelog []byte, prefix string) (start, end int) {
+	heading := []byte(sectionHeading + prefix)
+	start = -1
+
+	for offset := 0; offset < len(changelog); {
+		next := len(changelog)
+
+		if i := bytes.IndexByte(changelog[offset:], '\n'); i >= 0 {
+			next = offset + i + 1
+		}
+
+		line := changelog[offset:next]
+
+		if bytes.HasPrefix(line, []byte(sectionHeading)) {
+			if start >= 0 {
+				return start, offset
+			}
+
+			if bytes.HasPrefix(line, heading) {
+				start = offset
+			}
+		}
+
+		offset = next
+	}
+
+	if start < 0 {
+		return -1, -1
+	}
+
+	return start, len(changelog)
+}
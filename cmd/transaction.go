@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/postfinance/flash"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+)
+
+// transaction records the steps of a release. If a step fails, all completed
+// steps are undone in reverse order.
+type transaction struct {
+	l    *flash.Logger
+	done []undoStep
+}
+
+type undoStep struct {
+	name string
+	undo func(ctx context.Context) error
+}
+
+// run runs step. If step fails, the transaction is rolled back and a
+// rollbackError is returned. Otherwise undo is recorded under the name
+// undoName. If undo is nil, the step cannot be undone (i.e: a push).
+func (t *transaction) run(name string, step func() error, undoName string, undo func(ctx context.Context) error) error {
+	t.l.Debug(name)
+
+	if err := step(); err != nil {
+		return t.rollback(fmt.Errorf("%s: %w", name, err))
+	}
+
+	if undo != nil {
+		t.done = append(t.done, undoStep{name: undoName, undo: undo})
+	}
+
+	return nil
+}
+
+// rollback undoes all completed steps. The steps are undone with a new context,
+// because the context of the release may be canceled.
+func (t *transaction) rollback(cause error) error {
+	err := &rollbackError{cause: cause}
+
+	for i := len(t.done) - 1; i >= 0; i-- {
+		s := t.done[i]
+		t.l.Debugw("rollback", "step", s.name)
+
+		if e := s.undo(context.Background()); e != nil {
+			err.failed = append(err.failed, fmt.Sprintf("%s: %s", s.name, strings.TrimSpace(e.Error())))
+			continue
+		}
+
+		err.undone = append(err.undone, s.name)
+	}
+
+	t.done = nil
+
+	return err
+}
+
+// rollbackError is the error of a failed release. It reports which steps
+// have been undone.
+type rollbackError struct {
+	cause  error
+	undone []string
+	failed []string
+}
+
+func (e *rollbackError) Error() string {
+	var s strings.Builder
+
+	s.WriteString(strings.TrimSpace(e.cause.Error()))
+
+	if len(e.undone) == 0 && len(e.failed) == 0 {
+		s.WriteString("\nnothing to roll back")
+	}
+
+	if len(e.undone) > 0 {
+		s.WriteString("\nrolled back:")
+
+		for _, u := range e.undone {
+			s.WriteString("\n  - " + u)
+		}
+	}
+
+	if len(e.failed) > 0 {
+		s.WriteString("\nrollback failed, please clean up manually:")
+
+		for _, f := range e.failed {
+			s.WriteString("\n  - " + f)
+		}
+	}
+
+	return s.String()
+}
+
+func (e *rollbackError) Unwrap() error {
+	return e.cause
+}
+
+// releaseFile is a file of the release commit (a changelog or version file)
+// with its new content.
+type releaseFile struct {
+	path    string
+	content []byte
+}
+
+// releaseTag is a release tag created in the repository g.
+type releaseTag struct {
+	g       git.Repository
+	version string
+	last    git.Tag // the last release tag, empty for the first release
+	pkg     string  // the package name, empty if no packages are configured
+	notes   []byte  // the rendered changelog section
+}
+
+// verifyTags verifies that the release tags do not exist locally or on remote
+// and that HEAD is not already a release. Only a release can be created on top
+// of a pre-release or, if a 0.x release is promoted, on top of a 0.x release.
+func verifyTags(ctx context.Context, remote string, tags []releaseTag) error {
+	for _, t := range tags {
+		name := t.g.TagName(t.version)
+
+		exists, err := t.g.TagExists(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return fmt.Errorf("tag %s already exists", name)
+		}
+
+		exists, err = t.g.RemoteTagExists(ctx, remote, name)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return fmt.Errorf("tag %s already exists on the remote", name)
+		}
+
+		head, err := t.g.PointsAt(ctx, "HEAD")
+		if err != nil {
+			return err
+		}
+
+		version, err := semver.NewVersion(t.version)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range head {
+			promoted := h.IsPrerelease() || (h.Version.Major() == 0 && version.Major() > 0)
+			if !promoted || version.Prerelease() != "" {
+				return fmt.Errorf("HEAD is already tagged with %s", h.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// remoteState checks which release refs have reached the remote after a failed
+// non-atomic push. It returns a description of each ref and whether a ref may
+// have been updated on the remote. Refs that cannot be checked count as
+// updated.
+func remoteState(g git.Repository, remote string, tags []releaseTag) ([]string, bool) {
+	// the context of the release may be canceled
+	ctx := context.Background()
+
+	var (
+		state   []string
+		applied bool
+	)
+
+	add := func(kind, name string, exists bool, err error) {
+		switch {
+		case err != nil:
+			state = append(state, fmt.Sprintf("%s %s: %s", kind, name, strings.TrimSpace(err.Error())))
+		case exists:
+			state = append(state, fmt.Sprintf("%s %s is on '%s'", kind, name, remote))
+		default:
+			state = append(state, fmt.Sprintf("%s %s is not on '%s'", kind, name, remote))
+		}
+
+		applied = applied || exists || err != nil
+	}
+
+	branch, err := g.CurrentBranch(ctx)
+	if err == nil && branch != "" {
+		exists, err := g.RemoteBranchAt(ctx, remote, branch, "HEAD")
+		add("branch", branch, exists, err)
+	} else if err != nil {
+		add("branch", "HEAD", false, err)
+	}
+
+	for _, t := range tags {
+		name := t.g.TagName(t.version)
+
+		exists, err := t.g.RemoteTagExists(ctx, remote, name)
+		add("tag", name, exists, err)
+	}
+
+	return state, applied
+}
+
+// partialPushError reports the state of the release refs on the remote after
+// a non-atomic push that has been applied in part. The release commit and tags
+// are kept.
+func partialPushError(state []string, cause error) error {
+	var s strings.Builder
+
+	fmt.Fprintf(&s, "push: %s\nthe push has been applied in part, the release commit and tags are kept:", strings.TrimSpace(cause.Error()))
+
+	for _, r := range state {
+		s.WriteString("\n  - " + r)
+	}
+
+	return errors.New(s.String())
+}
+
+// publish writes, stages and commits the files, creates the release
+// tags and pushes everything with the configured push strategy. The commit and
+// tag messages are rendered from the configured templates and the configured
+// hooks run between the steps. If a step fails, all completed steps are undone:
+// tags are deleted, the commit is reset and the files are restored. A failed
+// non-atomic push is only undone if no release ref reached the remote.
+// nolint: gocyclo,funlen
+func (c Command) publish(ctx context.Context, l *flash.Logger, cfg config.Changelog, g git.Repository, files []releaseFile, tags []releaseTag) error {
+	remote, strategy := c.remoteName(cfg), c.pushStrategy(cfg)
+
+	msg, err := commitMessage(tags).render("commit", cfg.Messages.CommitTemplate(len(cfg.Packages) > 0))
+	if err != nil {
+		return err
+	}
+
+	tagMsgs := make([]string, 0, len(tags))
+
+	for _, t := range tags {
+		m, err := tagMessage(t).render("tag", cfg.Messages.TagTemplate())
+		if err != nil {
+			return err
+		}
+
+		tagMsgs = append(tagMsgs, m)
+	}
+
+	if err := verifyTags(ctx, remote, tags); err != nil {
+		return err
+	}
+
+	if cfg.Sign.VerifyTag || *c.verifyTag {
+		for _, t := range tags {
+			if t.last.Name == "" {
+				continue
+			}
+
+			if err := t.g.VerifyTag(ctx, t.last.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	opts := git.PushOptions{Remote: remote, Atomic: strategy == config.PushAtomic}
+
+	if strategy == config.PushExplicit || strategy == config.PushAtomic {
+		branch, err := g.CurrentBranch(ctx)
+		if err != nil {
+			return err
+		}
+
+		if branch == "" {
+			return fmt.Errorf("cannot push a detached HEAD with push strategy '%s'", strategy)
+		}
+
+		opts.Refs = append(opts.Refs, "HEAD:refs/heads/"+branch)
+
+		for _, t := range tags {
+			opts.Refs = append(opts.Refs, "refs/tags/"+t.g.TagName(t.version))
+		}
+	}
+
+	toplevelDir, err := g.TopLevelDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	hooks, err := newHookRunner(l, toplevelDir, tags)
+	if err != nil {
+		return err
+	}
+	defer hooks.close()
+
+	tx := transaction{l: l}
+
+	// a failing hook before the push rolls back the release
+	runHook := func(name string, commands []string) error {
+		if len(commands) == 0 {
+			return nil
+		}
+
+		return tx.run(name+" hook", func() error {
+			return hooks.run(ctx, name, commands)
+		}, "", nil)
+	}
+
+	if err := runHook("pre_changelog", cfg.Hooks.PreChangelog); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(files))
+
+	for i := range files {
+		f := files[i]
+
+		old, err := os.ReadFile(f.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		tracked := g.IsStaged(ctx, f.path)
+
+		err = tx.run("update "+f.path, func() error {
+			return os.WriteFile(f.path, f.content, 0o600)
+		}, "restore "+f.path, func(context.Context) error {
+			if len(old) == 0 && !tracked {
+				return os.Remove(f.path)
+			}
+
+			return os.WriteFile(f.path, old, 0o600)
+		})
+		if err != nil {
+			return err
+		}
+
+		if !tracked {
+			err := tx.run("stage "+f.path, func() error {
+				return g.StageFile(ctx, f.path)
+			}, "unstage "+f.path, func(ctx context.Context) error {
+				return g.UnstageFile(ctx, f.path)
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		paths = append(paths, f.path)
+	}
+
+	if err := runHook("pre_commit", cfg.Hooks.PreCommit); err != nil {
+		return err
+	}
+
+	if len(cfg.Hooks.PreChangelog) > 0 || len(cfg.Hooks.PreCommit) > 0 {
+		if paths, err = stageHookChanges(ctx, &tx, g, toplevelDir, paths); err != nil {
+			return err
+		}
+	}
+
+	err = tx.run("commit changes", func() error {
+		return g.CommitFiles(ctx, msg, paths...)
+	}, "reset commit '"+strings.SplitN(msg, "\n", 2)[0]+"'", func(ctx context.Context) error {
+		return g.ResetCommit(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range tags {
+		t, tagMsg := tags[i], tagMsgs[i]
+		name := t.g.TagName(t.version)
+
+		err := tx.run("create tag "+name, func() error {
+			return t.g.CreateRelease(ctx, t.version, tagMsg)
+		}, "delete tag "+name, func(ctx context.Context) error {
+			return t.g.DeleteRelease(ctx, t.version)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := runHook("post_tag", cfg.Hooks.PostTag); err != nil {
+		return err
+	}
+
+	if strategy == config.PushNone {
+		fmt.Printf("push strategy is '%s', push the release to '%s' yourself\n", config.PushNone, remote)
+		return nil
+	}
+
+	if opts.Atomic {
+		err = tx.run("push", func() error {
+			return g.Push(ctx, opts)
+		}, "", nil)
+		if err != nil {
+			return err
+		}
+	} else if err := g.Push(ctx, opts); err != nil {
+		// a non-atomic push may have updated some refs on the remote, the
+		// release is only undone if none of them reached the remote
+		state, applied := remoteState(g, remote, tags)
+		if applied {
+			return partialPushError(state, err)
+		}
+
+		return tx.rollback(fmt.Errorf("push: %w", err))
+	}
+
+	if len(cfg.Hooks.PostPush) == 0 {
+		return nil
+	}
+
+	if err := hooks.run(ctx, "post_push", cfg.Hooks.PostPush); err != nil {
+		return fmt.Errorf("post_push hook: %w (the release has already been pushed)", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Masterminds/semver"
+	"github.com/postfinance/flash"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+)
+
+func (c Command) runUnreleased(ctx context.Context, dst io.Writer, l *flash.Logger, cfg config.Changelog, g git.Repository) error {
+	tags, err := g.MergedTags(ctx, "HEAD")
+	if err != nil {
+		return err
+	}
+
+	allTags, err := g.ListTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	unreleasedTitle := cfg.Localized().Unreleased
+
+	// unreleased are all changes since the last release
+	stable := lastStable(tags)
+
+	commits, err := g.Log(ctx, tagRev(stable), "HEAD")
+	if err != nil {
+		return err
+	}
+
+	var section bytes.Buffer
+
+	if len(commits) > 0 {
+		cw, err := c.createChangelog(cfg, l, commits)
+		if err != nil {
+			return err
+		}
+
+		next, _ := semver.NewVersion(initialVersion)
+
+		if len(tags) > 0 {
+			next, err = c.releaseVersion(tags, allTags, cw.ReleaseType(), cfg)
+			if err != nil {
+				return err
+			}
+		}
+
+		// nothing to release: no version is shown
+		title := unreleasedTitle
+		if next != nil {
+			title = fmt.Sprintf("%s (%s)", unreleasedTitle, next)
+		}
+
+		l.Debugw("unreleased changes", "commits", len(commits), "title", title)
+
+		cw.Write(title, &section)
+	}
+
+	if *c.toStdOut {
+		_, err := dst.Write(section.Bytes())
+		return err
+	}
+
+	old, err := os.ReadFile(*c.file)
+	if err != nil {
+		return err
+	}
+
+	updated, ok := replaceSection(old, section.Bytes(), unreleasedTitle)
+	if !ok {
+		updated = append(section.Bytes(), old...)
+	}
+
+	l.Debugw("update unreleased section", "file", *c.file)
+
+	if _, err := dst.Write(updated); err != nil {
+		return err
+	}
+
+	return truncate(dst)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/zbindenren/cc/config"
+	"github.com/zbindenren/cc/git"
+	"github.com/zbindenren/cc/internal/changelog"
+)
+
+// releaseVersion returns the version of the next release. The version is
+// computed from the last release in tags and the release type of all changes
+// since that release. A pre-release is promoted to its release version. If
+// there is nothing to release, nil is returned.
+//
+// Both merged and all are expected to be sorted in descending order. merged
+// contains only the tags reachable from HEAD, all contains all tags and is used
+// to determine the next pre-release identifier.
+func (c Command) releaseVersion(merged, all git.Tags, releaseType changelog.ReleaseType, cfg config.Changelog) (*semver.Version, error) {
+	var err error
+
+	last := merged[0].Version
+	next := *semver.MustParse(initialVersion)
+
+	if stable := lastStable(merged); stable.Name != "" {
+		next = nextVersion(stable.Version, releaseType, cfg.ZeroMajor)
+	}
+
+	switch {
+	case last.Prerelease() != "":
+		base, _ := last.SetPrerelease("")
+		base, _ = base.SetMetadata("")
+
+		if base.GreaterThan(&next) {
+			next = base
+		}
+	case releaseType == changelog.None && !*c.promote:
+		return nil, nil
+	}
+
+	if *c.promote {
+		if last.Major() > 0 && last.Prerelease() == "" {
+			return nil, fmt.Errorf("cannot promote to %s: current version %s is already stable", stableVersion, last)
+		}
+
+		// a pre-release of 1.0.0 or higher is promoted to its release version
+		if stable := semver.MustParse(stableVersion); stable.GreaterThan(&next) {
+			next = *stable
+		}
+	}
+
+	next, err = c.applyIdentifiers(next, all)
+	if err != nil {
+		return nil, err
+	}
+
+	return &next, nil
+}
+
+// applyIdentifiers adds the pre-release identifier and build metadata to the
+// version if configured.
+func (c Command) applyIdentifiers(version semver.Version, tags git.Tags) (semver.Version, error) {
+	var err error
+
+	if *c.pre != "" {
+		version, err = nextPrerelease(version, *c.pre, tags)
+		if err != nil {
+			return version, err
+		}
+	}
+
+	if *c.build != "" {
+		version, err = version.SetMetadata(*c.build)
+		if err != nil {
+			return version, fmt.Errorf("invalid build metadata '%s': %w", *c.build, err)
+		}
+	}
+
+	return version, nil
+}
+
+// nextVersion increases the current version depending on the release type.
+// For versions below 1.0.0 the zero major policy is applied.
+func nextVersion(current *semver.Version, releaseType changelog.ReleaseType, zeroMajor string) semver.Version {
+	if current.Major() == 0 && zeroMajor == config.ZeroMajorShift && releaseType > changelog.Patch {
+		releaseType--
+	}
+
+	switch releaseType {
+	case changelog.None:
+		return *current
+	case changelog.Minor:
+		return current.IncMinor()
+	case changelog.Major:
+		return current.IncMajor()
+	default:
+		return current.IncPatch()
+	}
+}
+
+// nextPrerelease returns version with the pre-release identifier <channel>.<n>.
+// n is one higher than the highest identifier of the existing tags with the
+// same version and channel.
+func nextPrerelease(version semver.Version, channel string, tags git.Tags) (semver.Version, error) {
+	n := 0
+
+	for _, t := range tags {
+		v := t.Version
+		if v.Major() != version.Major() || v.Minor() != version.Minor() || v.Patch() != version.Patch() {
+			continue
+		}
+
+		if !strings.HasPrefix(v.Prerelease(), channel+".") {
+			continue
+		}
+
+		i, err := strconv.Atoi(strings.TrimPrefix(v.Prerelease(), channel+"."))
+		if err == nil && i > n {
+			n = i
+		}
+	}
+
+	next, err := version.SetPrerelease(fmt.Sprintf("%s.%d", channel, n+1))
+	if err != nil {
+		return version, fmt.Errorf("invalid pre-release channel '%s': %w", channel, err)
+	}
+
+	return next, nil
+}
+
+// lastStable returns the first tag in tags that is not a pre-release. If no
+// such tag exists, an empty tag is returned.
+func lastStable(tags git.Tags) git.Tag {
+	for _, t := range tags {
+		if !t.IsPrerelease() {
+			return t
+		}
+	}
+
+	return git.Tag{}
+}
+
+// previousTag returns the tag the changes of tags[i] are computed from. For a
+// pre-release this is the next older tag, for a release the next older
+// release. If there is no such tag, an empty tag is returned.
+func previousTag(tags git.Tags, i int) git.Tag {
+	if i+1 >= len(tags) {
+		return git.Tag{}
+	}
+
+	if tags[i].IsPrerelease() {
+		return tags[i+1]
+	}
+
+	return lastStable(tags[i+1:])
+}
+
+// tagRev returns the revision of a tag. For an empty tag an empty revision is
+// returned.
+func tagRev(tag git.Tag) string {
+	if tag.Name == "" {
+		return ""
+	}
+
+	return "tags/" + tag.Name
+}
@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
+	"text/template"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +19,22 @@ import (
 const (
 	// FileName is the configuration file name.
 	FileName = ".cc.yml"
+	// BreakingType is the section type of the breaking changes section. A
+	// section with this type configures title and position of the breaking
+	// changes section.
+	BreakingType = "breaking"
+	// DefaultBreakingTitle is the english title of the breaking changes
+	// section.
+	DefaultBreakingTitle = "Breaking Changes"
+)
+
+// Section orders.
+const (
+	// OrderAlphabetical orders sections alphabetically by title. The breaking
+	// changes section is always the first section.
+	OrderAlphabetical = "alphabetical"
+	// OrderConfig orders sections in the same order as they are configured.
+	OrderConfig = "config"
 )
 
 // common errors
@@ -23,71 +43,580 @@ var (
 	ErrEmpty    = errors.New("empty config")
 )
 
+// Commit orders within a scope.
+const (
+	// CommitOrderAlphabetical orders commits by description.
+	CommitOrderAlphabetical = "alphabetical"
+	// CommitOrderChronological orders commits from oldest to newest.
+	CommitOrderChronological = "chronological"
+	// CommitOrderReverseChronological orders commits from newest to oldest.
+	CommitOrderReverseChronological = "reverse-chronological"
+	// CommitOrderIssue groups commits by the referenced issue. Commits without
+	// issue reference are listed last.
+	CommitOrderIssue = "issue"
+)
+
+// Version bumps.
+const (
+	BumpMajor = "major"
+	BumpMinor = "minor"
+	BumpPatch = "patch"
+	BumpNone  = "none"
+)
+
+// Policies for versions below 1.0.0.
+const (
+	// ZeroMajorStrict increases versions below 1.0.0 the same way as all other
+	// versions: a breaking change leads to version 1.0.0.
+	ZeroMajorStrict = "strict"
+	// ZeroMajorShift shifts version increases below 1.0.0 by one level: a
+	// breaking change increases the minor and a feature the patch version.
+	ZeroMajorShift = "shift"
+)
+
+// Changelog layouts.
+const (
+	// LayoutTypeScope groups commits by type and then by scope.
+	LayoutTypeScope = "type-scope"
+	// LayoutType lists all commits of a type in a flat list. The scope is
+	// only shown if a commit has one.
+	LayoutType = "type"
+	// LayoutScopeType groups commits by scope and then by type.
+	LayoutScopeType = "scope-type"
+)
+
+// Push strategies.
+const (
+	// PushFollowTags pushes the current branch with all annotated tags
+	// reachable from it.
+	PushFollowTags = "follow-tags"
+	// PushExplicit pushes the current branch and the release tags
+	// explicitly.
+	PushExplicit = "explicit"
+	// PushAtomic pushes the current branch and the release tags explicitly
+	// in one atomic transaction: either all refs are updated or none.
+	PushAtomic = "atomic"
+	// PushNone does not push at all. The release commit and tags are only
+	// created locally.
+	PushNone = "none"
+)
+
+// DefaultRemote is the default remote releases are pushed to.
+const DefaultRemote = "origin"
+
+// Default message templates.
+const (
+	// DefaultCommitMessage is the template of the release commit message.
+	DefaultCommitMessage = "chore: update changelog with {{ .Version }} release"
+	// DefaultPackagesCommitMessage is the template of the release commit
+	// message if packages are configured.
+	DefaultPackagesCommitMessage = "chore: update changelogs with {{ .Version }} release"
+	// DefaultTagMessage is the template of the tag annotation.
+	DefaultTagMessage = "chore: bump version to {{ .Version }}"
+)
+
 // Changelog configures the changelog.
 type Changelog struct {
-	Sections          []Section `yaml:"sections"`
-	GithubProjectPath string    `yaml:"github_project_path"`
+	Sections             []Section  `yaml:"sections"`
+	SectionOrder         string     `yaml:"section_order,omitempty"`
+	CommitOrder          string     `yaml:"commit_order,omitempty"`
+	Layout               string     `yaml:"layout,omitempty"`
+	CaseInsensitiveTypes bool       `yaml:"case_insensitive_types,omitempty"`
+	ZeroMajor            string     `yaml:"zero_major,omitempty"`
+	Scopes               []Scope    `yaml:"scopes,omitempty"`
+	RestrictScopes       bool       `yaml:"restrict_scopes,omitempty"`
+	Language             string     `yaml:"language,omitempty"`
+	Labels               Labels     `yaml:"labels,omitempty"`
+	Tag                  Tag        `yaml:"tag,omitempty"`
+	Packages             []Package  `yaml:"packages,omitempty"`
+	Branches             []string   `yaml:"branches,omitempty"` // glob patterns of branches releases can be created from
+	Remote               string     `yaml:"remote,omitempty"`   // defaults to origin
+	Push                 string     `yaml:"push,omitempty"`     // defaults to follow-tags
+	Sign                 Sign       `yaml:"sign,omitempty"`
+	Messages             Messages   `yaml:"messages,omitempty"`
+	BumpFiles            []BumpFile `yaml:"bump_files,omitempty"` // version files updated with each release
+	Hooks                Hooks      `yaml:"hooks,omitempty"`
+	GithubProjectPath    string     `yaml:"github_project_path"`
+}
+
+// Package configures a package of a monorepo. Each package has its own
+// version, release tags and changelog file.
+type Package struct {
+	Name      string     `yaml:"name"`
+	Path      string     `yaml:"path"`                 // only commits touching this path belong to the package
+	Changelog string     `yaml:"changelog,omitempty"`  // defaults to <path>/CHANGELOG.md
+	Tag       Tag        `yaml:"tag,omitempty"`        // defaults to tags with the package name as component
+	Scopes    []Scope    `yaml:"scopes,omitempty"`     // if set, replaces the global scope configuration
+	BumpFiles []BumpFile `yaml:"bump_files,omitempty"` // version files updated with each release of the package
+}
+
+// BumpFile is a file containing the version that is updated with each release and
+// committed together with the changelog. Without pattern and key, the whole
+// file is replaced by the version.
+type BumpFile struct {
+	Path    string `yaml:"path"`              // relative to the repository root
+	Pattern string `yaml:"pattern,omitempty"` // regular expression, the first group (or the whole match) is replaced by the version
+	Key     string `yaml:"key,omitempty"`     // dot separated key in a YAML or JSON file (i.e: dependencies.version)
+}
+
+func (b BumpFile) validate() error {
+	if b.Path == "" {
+		return errors.New("path of bump file cannot be empty")
+	}
+
+	if b.Pattern != "" && b.Key != "" {
+		return fmt.Errorf("bump file '%s': pattern and key are mutually exclusive", b.Path)
+	}
+
+	if _, err := regexp.Compile(b.Pattern); err != nil {
+		return fmt.Errorf("bump file '%s': invalid pattern: %w", b.Path, err)
+	}
+
+	return nil
+}
+
+func validateBumpFiles(files []BumpFile) error {
+	for _, b := range files {
+		if err := b.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ChangelogFile returns the path of the changelog file of the package.
+func (p Package) ChangelogFile() string {
+	if p.Changelog != "" {
+		return p.Changelog
+	}
+
+	return filepath.Join(p.Path, "CHANGELOG.md")
+}
+
+// Package returns the configuration for a package.
+func (c Changelog) Package(p Package) Changelog {
+	pc := c
+	pc.Packages = nil
+	pc.Tag = p.Tag
+	pc.BumpFiles = p.BumpFiles
+
+	if p.Tag.isZero() {
+		pc.Tag = Tag{Component: p.Name}
+	}
+
+	if len(p.Scopes) > 0 {
+		pc.Scopes = p.Scopes
+	}
+
+	return pc
+}
+
+// Tag configures the names of release tags: [<component>/]<prefix>[v]<version>.
+// Tags that do not match are ignored.
+type Tag struct {
+	Component string   `yaml:"component,omitempty"`
+	Prefix    string   `yaml:"prefix,omitempty"`
+	OmitV     bool     `yaml:"omit_v,omitempty"`
+	Include   []string `yaml:"include,omitempty"` // if set, only tags matching one of these glob patterns are considered
+	Exclude   []string `yaml:"exclude,omitempty"` // tags matching one of these glob patterns are ignored
+}
+
+// Sign configures the signing of release commits and tags. Whether GPG or SSH
+// keys are used is configured with gpg.format in the git configuration.
+type Sign struct {
+	Commit    bool   `yaml:"commit,omitempty"`
+	Tag       bool   `yaml:"tag,omitempty"`
+	Key       string `yaml:"key,omitempty"`        // defaults to user.signingkey
+	VerifyTag bool   `yaml:"verify_tag,omitempty"` // verify the signature of the last release tag before releasing
+}
+
+// Hooks configures shell commands that run at defined points of a release.
+// The commands run in the repository root, a failing command aborts the
+// release.
+type Hooks struct {
+	PreChangelog []string `yaml:"pre_changelog,omitempty"` // before the changelog and version files are written
+	PreCommit    []string `yaml:"pre_commit,omitempty"`    // before the release commit is created
+	PostTag      []string `yaml:"post_tag,omitempty"`      // after the release tags are created
+	PostPush     []string `yaml:"post_push,omitempty"`     // after the release is pushed
+}
+
+// Messages configures the templates (text/template) of the release commit
+// message and the tag annotation.
+type Messages struct {
+	Commit string `yaml:"commit,omitempty"` // defaults to DefaultCommitMessage or DefaultPackagesCommitMessage
+	Tag    string `yaml:"tag,omitempty"`    // defaults to DefaultTagMessage
+}
+
+// CommitTemplate returns the template of the release commit message.
+func (m Messages) CommitTemplate(packages bool) string {
+	switch {
+	case m.Commit != "":
+		return m.Commit
+	case packages:
+		return DefaultPackagesCommitMessage
+	default:
+		return DefaultCommitMessage
+	}
+}
+
+// TagTemplate returns the template of the tag annotation.
+func (m Messages) TagTemplate() string {
+	if m.Tag != "" {
+		return m.Tag
+	}
+
+	return DefaultTagMessage
+}
+
+func (m Messages) validate() error {
+	if _, err := template.New("commit").Parse(m.Commit); err != nil {
+		return fmt.Errorf("invalid commit message template: %w", err)
+	}
+
+	if _, err := template.New("tag").Parse(m.Tag); err != nil {
+		return fmt.Errorf("invalid tag message template: %w", err)
+	}
+
+	return nil
+}
+
+func (t Tag) isZero() bool {
+	return t.Component == "" && t.Prefix == "" && !t.OmitV && len(t.Include) == 0 && len(t.Exclude) == 0
+}
+
+func (t Tag) validate() error {
+	for _, p := range append(append([]string{}, t.Include...), t.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid tag pattern '%s': %w", p, err)
+		}
+	}
+
+	return nil
 }
 
 // Section is a section config.
 type Section struct {
-	Type   string `yaml:"type"`
-	Title  string `yaml:"title"`
-	Hidden bool   `yaml:"hidden"`
+	Type    string   `yaml:"type"`
+	Title   string   `yaml:"title"`
+	Hidden  bool     `yaml:"hidden"`
+	Aliases []string `yaml:"aliases,omitempty"`
+	Bump    string   `yaml:"bump,omitempty"`
+}
+
+// Scope is a scope config.
+type Scope struct {
+	Name    string   `yaml:"name"`
+	Title   string   `yaml:"title,omitempty"`
+	Aliases []string `yaml:"aliases,omitempty"`
 }
 
 // Title creates the title from the header type.
 func (c Changelog) Title(headerType string) (title string, ok bool) {
-	for _, s := range c.Sections {
-		if s.Type == headerType {
-			return s.Title, true
+	s, ok := c.section(headerType)
+	if !ok {
+		return "", false
+	}
+
+	return s.Title, true
+}
+
+// Type returns the configured type for a header type. The header type can be
+// a configured type or one of its aliases. If no section matches, the header
+// type is returned unchanged.
+func (c Changelog) Type(headerType string) string {
+	s, ok := c.section(headerType)
+	if !ok {
+		return headerType
+	}
+
+	return s.Type
+}
+
+// ScopeTitle returns the display name of a header scope. The scope can be a
+// configured scope name or one of its aliases. If the scope has no title
+// configured, the scope name is returned.
+func (c Changelog) ScopeTitle(headerScope string) (title string, ok bool) {
+	for _, s := range c.Scopes {
+		if s.Name != headerScope && !contains(s.Aliases, headerScope) {
+			continue
 		}
+
+		if s.Title == "" {
+			return s.Name, true
+		}
+
+		return s.Title, true
 	}
 
 	return "", false
 }
 
+// IsAllowedScope returns false if scopes are restricted and the header scope
+// is neither a configured scope name nor an alias. An empty scope is always
+// allowed.
+func (c Changelog) IsAllowedScope(headerScope string) bool {
+	if !c.RestrictScopes || headerScope == "" {
+		return true
+	}
+
+	_, ok := c.ScopeTitle(headerScope)
+
+	return ok
+}
+
+// IsAllowedBranch returns true if releases can be created from branch. If no
+// branches are configured, all branches are allowed.
+func (c Changelog) IsAllowedBranch(branch string) bool {
+	if len(c.Branches) == 0 {
+		return true
+	}
+
+	for _, p := range c.Branches {
+		if ok, _ := path.Match(p, branch); ok && branch != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bump returns how the version is increased for a header type. If no bump is
+// configured, 'feat' leads to a minor and all other types to a patch version
+// increase.
+func (c Changelog) Bump(headerType string) string {
+	s, ok := c.section(headerType)
+	if ok && s.Bump != "" {
+		return s.Bump
+	}
+
+	if c.Type(headerType) == "feat" {
+		return BumpMinor
+	}
+
+	return BumpPatch
+}
+
 // IsHidden returns true if section should be hidden in changelog.
 func (c Changelog) IsHidden(headerType string) bool {
+	s, ok := c.section(headerType)
+	if !ok {
+		return true
+	}
+
+	return s.Hidden
+}
+
+// section returns the section config of a header type. Aliases and (if
+// configured) case insensitive matching are considered.
+func (c Changelog) section(headerType string) (Section, bool) {
 	for _, s := range c.Sections {
-		if s.Type == headerType {
-			return s.Hidden
+		if c.typeEqual(s.Type, headerType) {
+			return s, true
+		}
+
+		for _, a := range s.Aliases {
+			if c.typeEqual(a, headerType) {
+				return s, true
+			}
 		}
 	}
 
-	return true
+	return Section{}, false
 }
 
-// List returns not hidden section titles.
+func (c Changelog) typeEqual(a, b string) bool {
+	if c.CaseInsensitiveTypes {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
+
+// BreakingTitle returns the title of the breaking changes section. The title
+// of a section with type BreakingType takes precedence over the localized
+// label.
+func (c Changelog) BreakingTitle() string {
+	if title, ok := c.Title(BreakingType); ok {
+		return title
+	}
+
+	return c.Localized().Breaking
+}
+
+// List returns not hidden section titles in the order they appear in the
+// changelog.
 func (c Changelog) List() []string {
-	l := make([]string, 0, len(c.Sections))
+	l := make([]string, 0, len(c.Sections)+1)
+
+	_, hasBreaking := c.Title(BreakingType)
+
+	if c.SectionOrder == OrderConfig {
+		if !hasBreaking {
+			l = append(l, c.BreakingTitle())
+		}
+
+		for _, s := range c.Sections {
+			if !s.Hidden {
+				l = append(l, s.Title)
+			}
+		}
+
+		return l
+	}
 
 	for _, s := range c.Sections {
-		if !s.Hidden {
+		if !s.Hidden && s.Type != BreakingType {
 			l = append(l, s.Title)
 		}
 	}
 
 	sort.Strings(l)
 
-	l = append([]string{"Breaking Changes"}, l...)
+	if !hasBreaking || !c.IsHidden(BreakingType) {
+		l = append([]string{c.BreakingTitle()}, l...)
+	}
 
 	return l
 }
 
 // Validate validates configuration.
 func (c Changelog) Validate() error {
+	types := map[string]bool{}
+
+	for _, s := range c.Sections {
+		if s.Title == "" {
+			return errors.New("title cannot be empty")
+		}
+
+		if s.Type == "" {
+			return errors.New("type cannot be empty")
+		}
+
+		t := c.normalizeType(s.Type)
+		if types[t] {
+			return fmt.Errorf("type or alias '%s' is configured more than once", t)
+		}
+
+		types[t] = true
+	}
+
+	return c.validateSettings()
+}
+
+// validateSettings validates the settings Load checks. Sections without title
+// or type and duplicate types have always been loaded and are only reported by
+// Validate.
+func (c Changelog) validateSettings() error {
+	switch c.SectionOrder {
+	case "", OrderAlphabetical, OrderConfig:
+	default:
+		return fmt.Errorf("unsupported section order '%s'", c.SectionOrder)
+	}
+
+	switch c.CommitOrder {
+	case "", CommitOrderAlphabetical, CommitOrderChronological, CommitOrderReverseChronological, CommitOrderIssue:
+	default:
+		return fmt.Errorf("unsupported commit order '%s'", c.CommitOrder)
+	}
+
+	switch c.Layout {
+	case "", LayoutTypeScope, LayoutType, LayoutScopeType:
+	default:
+		return fmt.Errorf("unsupported layout '%s'", c.Layout)
+	}
+
+	switch c.ZeroMajor {
+	case "", ZeroMajorStrict, ZeroMajorShift:
+	default:
+		return fmt.Errorf("unsupported zero major policy '%s'", c.ZeroMajor)
+	}
+
+	if err := validateScopes(c.Scopes); err != nil {
+		return err
+	}
+
+	if err := c.Tag.validate(); err != nil {
+		return err
+	}
+
+	for _, p := range c.Branches {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid branch pattern '%s': %w", p, err)
+		}
+	}
+
+	if err := ValidatePush(c.Push); err != nil {
+		return err
+	}
+
+	if err := c.Messages.validate(); err != nil {
+		return err
+	}
+
+	if err := validateBumpFiles(c.BumpFiles); err != nil {
+		return err
+	}
+
+	if len(c.BumpFiles) > 0 && len(c.Packages) > 0 {
+		return errors.New("bump files have to be configured per package if packages are configured")
+	}
+
+	if err := validatePackages(c.Packages); err != nil {
+		return err
+	}
+
+	if _, ok := translations[c.Language]; c.Language != "" && !ok {
+		return fmt.Errorf("unsupported language '%s'", c.Language)
+	}
+
+	types := map[string]bool{}
+
+	for _, s := range c.Sections {
+		types[c.normalizeType(s.Type)] = true
+	}
+
 	for _, s := range c.Sections {
 		if err := s.validate(); err != nil {
 			return err
 		}
+
+		for _, a := range s.Aliases {
+			a = c.normalizeType(a)
+			if types[a] {
+				return fmt.Errorf("type or alias '%s' is configured more than once", a)
+			}
+
+			types[a] = true
+		}
 	}
 
 	return nil
 }
 
+// normalizeType returns the type t as it is matched against commit types.
+func (c Changelog) normalizeType(t string) string {
+	if c.CaseInsensitiveTypes {
+		return strings.ToLower(t)
+	}
+
+	return t
+}
+
+// ValidatePush returns an error if strategy is not a supported push strategy.
+// An empty strategy is the default strategy.
+func ValidatePush(strategy string) error {
+	switch strategy {
+	case "", PushFollowTags, PushExplicit, PushAtomic, PushNone:
+		return nil
+	default:
+		return fmt.Errorf("unsupported push strategy '%s'", strategy)
+	}
+}
+
 // Load is looking for a configuration file named '.cc.yml' in dir. If found
-// it tries to unmarshal it into Changelog.
+// it tries to unmarshal it into Changelog. The settings are validated, but
+// sections without title or type are loaded as before.
 func Load(dir string) (*Changelog, error) {
 	configPath := filepath.Join(dir, FileName)
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
@@ -108,6 +637,10 @@ func Load(dir string) (*Changelog, error) {
 		return nil, ErrEmpty
 	}
 
+	if err := c.validateSettings(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", configPath, err)
+	}
+
 	return c, nil
 }
 
@@ -185,13 +718,75 @@ var Default = Changelog{
 }
 
 func (s Section) validate() error {
-	if s.Title == "" {
-		return errors.New("title cannot be empty")
+	switch s.Bump {
+	case "", BumpMajor, BumpMinor, BumpPatch, BumpNone:
+	default:
+		return fmt.Errorf("unsupported bump '%s' for type '%s'", s.Bump, s.Type)
 	}
 
-	if s.Type == "" {
-		return errors.New("type cannot be empty")
+	return nil
+}
+
+func validateScopes(scopes []Scope) error {
+	names := map[string]bool{}
+
+	for _, s := range scopes {
+		if s.Name == "" {
+			return errors.New("scope name cannot be empty")
+		}
+
+		for _, n := range append([]string{s.Name}, s.Aliases...) {
+			if names[n] {
+				return fmt.Errorf("scope or alias '%s' is configured more than once", n)
+			}
+
+			names[n] = true
+		}
+	}
+
+	return nil
+}
+
+func validatePackages(packages []Package) error {
+	names := map[string]bool{}
+
+	for _, p := range packages {
+		if p.Name == "" {
+			return errors.New("package name cannot be empty")
+		}
+
+		if p.Path == "" {
+			return fmt.Errorf("path of package '%s' cannot be empty", p.Name)
+		}
+
+		if names[p.Name] {
+			return fmt.Errorf("package '%s' is configured more than once", p.Name)
+		}
+
+		names[p.Name] = true
+
+		if err := validateScopes(p.Scopes); err != nil {
+			return fmt.Errorf("package '%s': %w", p.Name, err)
+		}
+
+		if err := p.Tag.validate(); err != nil {
+			return fmt.Errorf("package '%s': %w", p.Name, err)
+		}
+
+		if err := validateBumpFiles(p.BumpFiles); err != nil {
+			return fmt.Errorf("package '%s': %w", p.Name, err)
+		}
 	}
 
 	return nil
 }
+
+func contains(l []string, s string) bool {
+	for i := range l {
+		if l[i] == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -25,6 +26,19 @@ func TestWriteLoad(t *testing.T) {
 
 	_, err = Load(".")
 	require.Equal(t, ErrNotFound, err)
+
+	// sections without title have always been loaded
+	c = &Changelog{Sections: []Section{{Type: "feat"}}}
+	require.NoError(t, Write(tmpDir, *c))
+
+	_, err = Load(tmpDir)
+	require.NoError(t, err)
+
+	c.SectionOrder = "random"
+	require.NoError(t, Write(tmpDir, *c))
+
+	_, err = Load(tmpDir)
+	require.Error(t, err)
 }
 
 func TestIsHidden(t *testing.T) {
@@ -40,3 +54,246 @@ func TestTitle(t *testing.T) {
 	_, ok = Default.Title("not-exist")
 	assert.False(t, ok)
 }
+
+func TestList(t *testing.T) {
+	var tt = []struct {
+		name     string
+		cfg      Changelog
+		expected []string
+	}{
+		{
+			"alphabetical",
+			Changelog{
+				Sections: []Section{
+					{Type: "feat", Title: "New Features"},
+					{Type: "fix", Title: "Bug Fixes"},
+					{Type: "deps", Title: "Dependencies"},
+					{Type: "chore", Title: "Tasks", Hidden: true},
+				},
+			},
+			[]string{"Breaking Changes", "Bug Fixes", "Dependencies", "New Features"},
+		},
+		{
+			"config",
+			Changelog{
+				SectionOrder: OrderConfig,
+				Sections: []Section{
+					{Type: "feat", Title: "New Features"},
+					{Type: "fix", Title: "Bug Fixes"},
+					{Type: "deps", Title: "Dependencies"},
+					{Type: "chore", Title: "Tasks", Hidden: true},
+				},
+			},
+			[]string{"Breaking Changes", "New Features", "Bug Fixes", "Dependencies"},
+		},
+		{
+			"config with breaking section",
+			Changelog{
+				SectionOrder: OrderConfig,
+				Sections: []Section{
+					{Type: "feat", Title: "New Features"},
+					{Type: "fix", Title: "Bug Fixes"},
+					{Type: BreakingType, Title: "Incompatible Changes"},
+				},
+			},
+			[]string{"New Features", "Bug Fixes", "Incompatible Changes"},
+		},
+		{
+			"alphabetical with breaking section",
+			Changelog{
+				Sections: []Section{
+					{Type: "fix", Title: "Bug Fixes"},
+					{Type: BreakingType, Title: "Incompatible Changes"},
+					{Type: "feat", Title: "New Features"},
+				},
+			},
+			[]string{"Incompatible Changes", "Bug Fixes", "New Features"},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cfg.List())
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Default.Validate())
+
+	c := Default
+	c.SectionOrder = "random"
+	assert.Error(t, c.Validate())
+
+	c = Default
+	c.Tag.Exclude = []string{"[deploy"}
+	assert.Error(t, c.Validate())
+
+	c = Default
+	c.Push = PushAtomic
+	assert.NoError(t, c.Validate())
+
+	c.Push = "force"
+	assert.Error(t, c.Validate())
+
+	c = Default
+	c.Messages.Tag = "{{ .Version"
+	assert.Error(t, c.Validate())
+
+	c = Default
+	c.BumpFiles = []BumpFile{{Path: "VERSION"}, {Path: "Chart.yaml", Key: "appVersion"}}
+	assert.NoError(t, c.Validate())
+
+	c.BumpFiles = []BumpFile{{Path: "version.go", Pattern: `Version = "(.*"`}}
+	assert.Error(t, c.Validate())
+
+	c.BumpFiles = []BumpFile{{Path: "package.json", Pattern: "(.*)", Key: "version"}}
+	assert.Error(t, c.Validate())
+
+	c.BumpFiles = []BumpFile{{Path: "VERSION"}}
+	c.Packages = []Package{{Name: "api", Path: "api"}}
+	assert.Error(t, c.Validate())
+}
+
+func TestLocalized(t *testing.T) {
+	assert.Equal(t, translations[DefaultLanguage], Default.Localized())
+
+	c := Default
+	c.Language = "de"
+	c.Labels.Scope = "gemeinsam"
+
+	l := c.Localized()
+	assert.Equal(t, "Inkompatible Änderungen", l.Breaking)
+	assert.Equal(t, "gemeinsam", l.Scope)
+	assert.Equal(t, "Inkompatible Änderungen", c.BreakingTitle())
+
+	c.Language = "xx"
+	assert.Error(t, c.Validate())
+}
+
+func TestScopes(t *testing.T) {
+	c := Default
+	c.Scopes = []Scope{
+		{Name: "api", Title: "REST API"},
+		{Name: "web", Title: "Web UI", Aliases: []string{"ui", "frontend"}},
+		{Name: "cli"},
+	}
+
+	title, ok := c.ScopeTitle("api")
+	assert.True(t, ok)
+	assert.Equal(t, "REST API", title)
+
+	title, ok = c.ScopeTitle("frontend")
+	assert.True(t, ok)
+	assert.Equal(t, "Web UI", title)
+
+	title, ok = c.ScopeTitle("cli")
+	assert.True(t, ok)
+	assert.Equal(t, "cli", title)
+
+	_, ok = c.ScopeTitle("other")
+	assert.False(t, ok)
+
+	assert.True(t, c.IsAllowedScope("other"))
+
+	c.RestrictScopes = true
+	assert.False(t, c.IsAllowedScope("other"))
+	assert.True(t, c.IsAllowedScope("ui"))
+	assert.True(t, c.IsAllowedScope(""))
+
+	assert.NoError(t, c.Validate())
+
+	c.Scopes = append(c.Scopes, Scope{Name: "ui"})
+	assert.Error(t, c.Validate())
+}
+
+func TestPackages(t *testing.T) {
+	c := Default
+	c.Tag = Tag{Prefix: "release-"}
+	c.Packages = []Package{
+		{Name: "api", Path: "services/api"},
+		{Name: "web", Path: "services/web", Changelog: "docs/CHANGES.md", Tag: Tag{Prefix: "web-"}, Scopes: []Scope{{Name: "ui"}}},
+	}
+
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, filepath.Join("services", "api", "CHANGELOG.md"), c.Packages[0].ChangelogFile())
+	assert.Equal(t, "docs/CHANGES.md", c.Packages[1].ChangelogFile())
+
+	api := c.Package(c.Packages[0])
+	assert.Equal(t, Tag{Component: "api"}, api.Tag)
+	assert.Nil(t, api.Packages)
+
+	web := c.Package(c.Packages[1])
+	assert.Equal(t, Tag{Prefix: "web-"}, web.Tag)
+	assert.Equal(t, []Scope{{Name: "ui"}}, web.Scopes)
+
+	c.Packages = append(c.Packages, Package{Name: "api", Path: "api"})
+	assert.Error(t, c.Validate())
+
+	c.Packages = []Package{{Name: "api"}}
+	assert.Error(t, c.Validate())
+}
+
+func TestIsAllowedBranch(t *testing.T) {
+	c := Default
+	assert.True(t, c.IsAllowedBranch("feature/x"))
+	assert.True(t, c.IsAllowedBranch(""))
+
+	c.Branches = []string{"main", "release/*"}
+	assert.True(t, c.IsAllowedBranch("main"))
+	assert.True(t, c.IsAllowedBranch("release/1.x"))
+	assert.False(t, c.IsAllowedBranch("feature/x"))
+	assert.False(t, c.IsAllowedBranch(""))
+	assert.NoError(t, c.Validate())
+
+	c.Branches = []string{"release/["}
+	assert.Error(t, c.Validate())
+}
+
+func TestTypeAliases(t *testing.T) {
+	c := Changelog{
+		Sections: []Section{
+			{Type: "feat", Title: "New Features", Aliases: []string{"feature"}},
+			{Type: "fix", Title: "Bug Fixes", Aliases: []string{"bugfix"}},
+		},
+	}
+
+	assert.Equal(t, "feat", c.Type("feature"))
+	assert.Equal(t, "fix", c.Type("bugfix"))
+	assert.Equal(t, "FIX", c.Type("FIX"))
+
+	title, ok := c.Title("bugfix")
+	assert.True(t, ok)
+	assert.Equal(t, "Bug Fixes", title)
+
+	c.CaseInsensitiveTypes = true
+	assert.Equal(t, "fix", c.Type("FIX"))
+	assert.Equal(t, "feat", c.Type("Feature"))
+	assert.False(t, c.IsHidden("Fix"))
+
+	assert.NoError(t, c.Validate())
+
+	c.Sections = append(c.Sections, Section{Type: "Feat", Title: "Features"})
+	assert.Error(t, c.Validate())
+}
+
+func TestBump(t *testing.T) {
+	c := Changelog{
+		Sections: []Section{
+			{Type: "feat", Title: "New Features"},
+			{Type: "perf", Title: "Performance", Bump: BumpMinor},
+			{Type: "docs", Title: "Documentation", Bump: BumpNone},
+		},
+	}
+
+	assert.Equal(t, BumpMinor, c.Bump("feat"))
+	assert.Equal(t, BumpMinor, c.Bump("perf"))
+	assert.Equal(t, BumpNone, c.Bump("docs"))
+	assert.Equal(t, BumpPatch, c.Bump("unknown"))
+	assert.NoError(t, c.Validate())
+
+	c.Sections[0].Bump = "huge"
+	assert.Error(t, c.Validate())
+}
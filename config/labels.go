@@ -0,0 +1,64 @@
+package config
+
+// DefaultLanguage is the language used if no language is configured.
+const DefaultLanguage = "en"
+
+// Labels are the built-in texts used in the changelog.
+type Labels struct {
+	Breaking   string `yaml:"breaking,omitempty"`   // title of the breaking changes section
+	Scope      string `yaml:"scope,omitempty"`      // scope name for commits without scope
+	Unreleased string `yaml:"unreleased,omitempty"` // title of the unreleased section
+}
+
+// translations contains the built-in labels for all supported languages.
+var translations = map[string]Labels{
+	"en": {
+		Breaking:   DefaultBreakingTitle,
+		Scope:      "common",
+		Unreleased: "Unreleased",
+	},
+	"de": {
+		Breaking:   "Inkompatible Änderungen",
+		Scope:      "allgemein",
+		Unreleased: "Unveröffentlicht",
+	},
+	"fr": {
+		Breaking:   "Changements incompatibles",
+		Scope:      "général",
+		Unreleased: "Non publié",
+	},
+	"it": {
+		Breaking:   "Modifiche incompatibili",
+		Scope:      "generale",
+		Unreleased: "Non rilasciato",
+	},
+	"es": {
+		Breaking:   "Cambios incompatibles",
+		Scope:      "general",
+		Unreleased: "Sin publicar",
+	},
+}
+
+// Localized returns the built-in labels for the configured language. Labels
+// configured explicitly take precedence over the translations.
+func (c Changelog) Localized() Labels {
+	l := translations[DefaultLanguage]
+
+	if t, ok := translations[c.Language]; ok {
+		l = t
+	}
+
+	if c.Labels.Breaking != "" {
+		l.Breaking = c.Labels.Breaking
+	}
+
+	if c.Labels.Scope != "" {
+		l.Scope = c.Labels.Scope
+	}
+
+	if c.Labels.Unreleased != "" {
+		l.Unreleased = c.Labels.Unreleased
+	}
+
+	return l
+}
@@ -0,0 +1,667 @@
+// Package git runs git commands.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/postfinance/flash"
+)
+
+// Command represents a git command.
+type Command struct {
+	Noop           bool          // if true no commands are performed that change git state (i.e: no commits and tags are created, no pushs are performed)
+	TagFormat      TagFormat     // the format of release tags
+	Timeout        time.Duration // timeout of a single local git command, 0 means no timeout
+	NetworkTimeout time.Duration // timeout of git commands that talk to a remote (push, fetch and ls-remote), 0 means no timeout
+	NoPrompt       bool          // if true, git fails instead of prompting for credentials
+	Signing        Signing       // the signing of release commits and tags
+	sshCommand     bool          // core.sshCommand is configured
+	l              *flash.Logger
+}
+
+// networkCommands are the git commands that talk to a remote.
+var networkCommands = map[string]bool{
+	"fetch":     true,
+	"ls-remote": true,
+	"push":      true,
+}
+
+// ErrNotFound is returned by New if there is no git binary.
+var ErrNotFound = errors.New("git command not found")
+
+// New Creates a new git command.
+func New(l *flash.Logger) (*Command, error) {
+	_, err := exec.LookPath("git")
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	return &Command{
+		sshCommand: hasSSHCommand(context.Background()),
+		l:          l,
+	}, nil
+}
+
+// Run runs the git command.
+func (c Command) Run(args ...string) (string, error) {
+	return c.RunContext(context.Background(), args...)
+}
+
+// RunContext runs the git command. The command is killed if ctx is done or
+// the timeout expires.
+func (c Command) RunContext(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := c.context(ctx, args[0])
+	defer cancel()
+
+	cmd := c.command(ctx, args...)
+
+	bts, err := cmd.CombinedOutput()
+	c.l.Debugw("git command", "args", strings.Join(cmd.Args, " "), "out", string(bts), "err", err)
+
+	if err := c.contextErr(ctx, args); err != nil {
+		return "", err
+	}
+
+	if err != nil {
+		return "", errors.New(string(bts))
+	}
+
+	return string(bts), nil
+}
+
+// context returns ctx with the configured timeout of the git command name.
+func (c Command) context(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+	if t := c.timeout(name); t > 0 {
+		return context.WithTimeout(ctx, t)
+	}
+
+	return context.WithCancel(ctx)
+}
+
+// timeout returns the timeout of the git command name.
+func (c Command) timeout(name string) time.Duration {
+	if networkCommands[name] {
+		return c.NetworkTimeout
+	}
+
+	return c.Timeout
+}
+
+// contextErr returns an error if ctx is canceled or the timeout expired.
+func (c Command) contextErr(ctx context.Context, args []string) error {
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return fmt.Errorf("git %s: timeout after %s", args[0], c.timeout(args[0]))
+	default:
+		return fmt.Errorf("git %s: %w", args[0], ctx.Err())
+	}
+}
+
+// command creates the git command. If NoPrompt is set, git and ssh fail
+// instead of asking for credentials. A configured ssh command (GIT_SSH_COMMAND
+// or core.sshCommand) is left untouched.
+func (c Command) command(ctx context.Context, args ...string) *exec.Cmd {
+	args = append([]string{"-c", "log.showSignature=false"}, args...)
+	cmd := exec.CommandContext(ctx, "git", args...) // nolint: gosec
+
+	if c.NoPrompt {
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never")
+
+		if os.Getenv("GIT_SSH_COMMAND") == "" && !c.sshCommand {
+			cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+		}
+	}
+
+	return cmd
+}
+
+// hasSSHCommand returns true if core.sshCommand is set in the git config.
+func hasSSHCommand(ctx context.Context) bool {
+	out, err := exec.CommandContext(ctx, "git", "config", "core.sshCommand").Output() // nolint: gosec
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(string(out)) != ""
+}
+
+// IsRepo returns true if current folder is a git repository.
+func (c Command) IsRepo(ctx context.Context) bool {
+	out, err := c.RunContext(ctx, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// TagDate gets the date of the tag.
+func (c Command) TagDate(ctx context.Context, tag string) (string, error) {
+	if tag == "" {
+		return "", errors.New("tag cannot be empty")
+	}
+
+	out, err := clean(c.RunContext(ctx, "log", "-1", "--format=%ai", tag))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Split(out, " ")[0], nil
+}
+
+// HasUncommitted checks if there are uncommitted changes.
+func (c Command) HasUncommitted(ctx context.Context) (bool, error) {
+	out, err := c.RunContext(ctx, "diff-index", "HEAD")
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(out) != "", nil
+}
+
+// HasRemote checks if the remote name is configured.
+func (c Command) HasRemote(ctx context.Context, name string) bool {
+	out, err := c.RunContext(ctx, "remote")
+
+	if err != nil {
+		return false
+	}
+
+	for _, r := range strings.Fields(out) {
+		if r == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsStaged checks if a path is staged in repository.
+func (c Command) IsStaged(ctx context.Context, path string) bool {
+	_, err := c.RunContext(ctx, "ls-files", "--error-unmatch", path)
+	return err == nil
+}
+
+// CreateRelease creates a release tag with the message msg. The tag name is
+// created with the configured tag format.
+func (c Command) CreateRelease(ctx context.Context, version, msg string) error {
+	// the message is kept verbatim, otherwise markdown headings of release
+	// notes are removed as comments
+	cmd := append(append([]string{"git", "tag", "--cleanup=verbatim"}, tagSignArgs(c.Signing)...), c.TagFormat.Name(version), "-m", msg)
+
+	if c.Noop {
+		c.l.Debugw("noop mode - command not run", "cmd", strings.Join(cmd, " "))
+		return nil
+	}
+
+	_, err := c.RunContext(ctx, cmd[1:]...)
+
+	return err
+}
+
+// CommitFiles commits files.
+func (c Command) CommitFiles(ctx context.Context, msg string, files ...string) error {
+	cmd := []string{"git", "commit", "--cleanup=verbatim"}
+
+	if c.Signing.Commit {
+		cmd = append(cmd, "-S"+c.Signing.Key)
+	}
+
+	cmd = append(append(cmd, "-m", msg, "--"), files...)
+
+	if c.Noop {
+		c.l.Debugw("noop mode - command not run", "cmd", strings.Join(cmd, " "))
+		return nil
+	}
+
+	_, err := c.RunContext(ctx, cmd[1:]...)
+
+	return err
+}
+
+// tagSignArgs returns the arguments of git tag to create an annotated tag
+// that is signed as configured by s.
+func tagSignArgs(s Signing) []string {
+	switch {
+	case s.Tag && s.Key != "":
+		return []string{"-u", s.Key}
+	case s.Tag:
+		return []string{"-s"}
+	default:
+		return []string{"-a"}
+	}
+}
+
+// VerifyTag verifies the signature of the tag name with git tag -v.
+func (c Command) VerifyTag(ctx context.Context, name string) error {
+	if _, err := c.RunContext(ctx, "tag", "-v", name); err != nil {
+		return fmt.Errorf("signature of tag %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// DeleteRelease deletes the local release tag of version.
+func (c Command) DeleteRelease(ctx context.Context, version string) error {
+	cmd := []string{"git", "tag", "-d", c.TagFormat.Name(version)}
+
+	if c.Noop {
+		c.l.Debugw("noop mode - command not run", "cmd", strings.Join(cmd, " "))
+		return nil
+	}
+
+	_, err := c.RunContext(ctx, cmd[1:]...)
+
+	return err
+}
+
+// ResetCommit removes the last commit. Its changes are kept in the working
+// tree, but not in the index.
+func (c Command) ResetCommit(ctx context.Context) error {
+	cmd := []string{"git", "reset", "-q", "HEAD~1"}
+
+	if c.Noop {
+		c.l.Debugw("noop mode - command not run", "cmd", strings.Join(cmd, " "))
+		return nil
+	}
+
+	_, err := c.RunContext(ctx, cmd[1:]...)
+
+	return err
+}
+
+// UnstageFile removes a newly staged file from the index. The file is kept in
+// the working tree.
+func (c Command) UnstageFile(ctx context.Context, file string) error {
+	cmd := []string{"git", "rm", "--cached", "-q", "--ignore-unmatch", "--", file}
+
+	if c.Noop {
+		c.l.Debugw("noop mode - command not run", "cmd", strings.Join(cmd, " "))
+		return nil
+	}
+
+	_, err := c.RunContext(ctx, cmd[1:]...)
+
+	return err
+}
+
+// ChangedFiles returns the tracked files that differ from HEAD in the index or
+// the working tree. The paths are relative to the top level directory.
+func (c Command) ChangedFiles(ctx context.Context) ([]string, error) {
+	out, err := c.RunContext(ctx, "diff", "--name-only", "-z", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+
+	for _, f := range strings.Split(out, "\x00") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+
+	return files, nil
+}
+
+// RestoreFile restores file in the index and the working tree from HEAD.
+func (c Command) RestoreFile(ctx context.Context, file string) error {
+	cmd := []string{"git", "checkout", "-q", "HEAD", "--", file}
+
+	if c.Noop {
+		c.l.Debugw("noop mode - command not run", "cmd", strings.Join(cmd, " "))
+		return nil
+	}
+
+	_, err := c.RunContext(ctx, cmd[1:]...)
+
+	return err
+}
+
+// StageFile stages a file.
+func (c Command) StageFile(ctx context.Context, file string) error {
+	cmd := []string{"git", "add", file}
+
+	if c.Noop {
+		c.l.Debugw("noop mode - command not run", "cmd", strings.Join(cmd, " "))
+		return nil
+	}
+
+	_, err := c.RunContext(ctx, cmd[1:]...)
+
+	return err
+}
+
+// Push pushes tags and commits.
+func (c Command) Push(ctx context.Context, opts PushOptions) error {
+	cmd := pushArgs(opts)
+
+	if c.Noop {
+		c.l.Debugw("noop mode - command not run", "cmd", strings.Join(cmd, " "))
+		return nil
+	}
+
+	_, err := c.RunContext(ctx, cmd[1:]...)
+
+	return err
+}
+
+// pushArgs returns the git command line of a push with opts.
+func pushArgs(opts PushOptions) []string {
+	cmd := []string{"git", "push"}
+
+	if opts.Atomic {
+		cmd = append(cmd, "--atomic")
+	}
+
+	if len(opts.Refs) == 0 {
+		return append(cmd, "--follow-tags", opts.Remote)
+	}
+
+	return append(append(cmd, opts.Remote), opts.Refs...)
+}
+
+// CurrentBranch returns the name of the current branch. For a detached HEAD an
+// empty string is returned.
+func (c Command) CurrentBranch(ctx context.Context) (string, error) {
+	out, err := c.RunContext(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	if branch := strings.TrimSpace(out); branch != "HEAD" {
+		return branch, nil
+	}
+
+	return "", nil
+}
+
+// SyncStatus fetches the remote of the current branch and compares the branch
+// with its upstream. If there is no upstream branch, an empty status is
+// returned.
+func (c Command) SyncStatus(ctx context.Context) (SyncStatus, error) {
+	upstream, err := c.RunContext(ctx, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{u}")
+	if err != nil {
+		c.l.Debugw("no upstream branch", "err", err)
+		return SyncStatus{}, nil
+	}
+
+	if _, err := c.RunContext(ctx, "fetch", "--quiet", "--no-tags"); err != nil {
+		return SyncStatus{}, fmt.Errorf("fetch: %w", err)
+	}
+
+	out, err := c.RunContext(ctx, "rev-list", "--left-right", "--count", "HEAD...@{u}")
+	if err != nil {
+		return SyncStatus{}, err
+	}
+
+	s := SyncStatus{
+		Upstream: strings.TrimSpace(upstream),
+	}
+
+	if _, err := fmt.Sscan(out, &s.Ahead, &s.Behind); err != nil {
+		return SyncStatus{}, fmt.Errorf("failed to parse '%s': %w", strings.TrimSpace(out), err)
+	}
+
+	return s, nil
+}
+
+// TagExists returns true if the tag name exists locally.
+func (c Command) TagExists(ctx context.Context, name string) (bool, error) {
+	out, err := c.RunContext(ctx, "tag", "--list", name)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(out) != "", nil
+}
+
+// RemoteTagExists returns true if the tag name exists on the remote.
+func (c Command) RemoteTagExists(ctx context.Context, remote, name string) (bool, error) {
+	out, err := c.RunContext(ctx, "ls-remote", "--tags", remote, "refs/tags/"+name)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(out) != "", nil
+}
+
+// RemoteBranchAt returns true if branch on the remote points at the local
+// revision.
+func (c Command) RemoteBranchAt(ctx context.Context, remote, branch, revision string) (bool, error) {
+	out, err := c.RunContext(ctx, "ls-remote", "--heads", remote, "refs/heads/"+branch)
+	if err != nil {
+		return false, err
+	}
+
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	rev, err := clean(c.RunContext(ctx, "rev-parse", revision))
+	if err != nil {
+		return false, err
+	}
+
+	return fields[0] == rev, nil
+}
+
+// PointsAt returns the release tags pointing at revision.
+func (c Command) PointsAt(ctx context.Context, revision string) (Tags, error) {
+	out, err := c.RunContext(ctx, "tag", "--list", "--points-at", revision, c.TagFormat.pattern())
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseTags(out), nil
+}
+
+// ListTags lists all release tags matching the tag format sorted by semantic
+// version in descending order.
+func (c Command) ListTags(ctx context.Context) (Tags, error) {
+	out, err := c.RunContext(ctx, "tag", "--list", c.TagFormat.pattern())
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseTags(out), nil
+}
+
+// MergedTags lists all release tags matching the tag format and reachable from
+// revision sorted by semantic version in descending order.
+func (c Command) MergedTags(ctx context.Context, revision string) (Tags, error) {
+	out, err := c.RunContext(ctx, "tag", "--list", "--merged", revision, c.TagFormat.pattern())
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parseTags(out), nil
+}
+
+func (c Command) parseTags(out string) Tags {
+	return parseTags(c.l, c.TagFormat, out)
+}
+
+// parseTags parses tag names (one per line) with the tag format f. Skipped
+// tags are logged.
+func parseTags(l *flash.Logger, f TagFormat, out string) Tags {
+	tags, skipped := f.parse(out)
+
+	for _, s := range skipped {
+		l.Debugw("skipping tag", "tag", s.name, "reason", s.reason)
+	}
+
+	return tags
+}
+
+// HasTags returns true if repository has release tags matching the tag
+// format.
+func (c Command) HasTags(ctx context.Context) (bool, error) {
+	tags, err := c.ListTags(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return len(tags) > 0, nil
+}
+
+// TopLevelDir returns the git top level directory.
+func (c Command) TopLevelDir(ctx context.Context) (string, error) {
+	topLevelDir, err := c.RunContext(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(topLevelDir), nil
+}
+
+// Log returns the commits start..end in the order of git rev-list (newest
+// first). If start is empty, all commits reachable from end are returned. If
+// paths are given, only commits touching these paths are returned. All commits
+// are read from a single git log process.
+func (c Command) Log(ctx context.Context, start, end string, paths ...string) ([]Commit, error) {
+	arg := end
+	if start != "" {
+		arg = start + ".." + end
+	}
+
+	args := []string{"log", "-z", "--format=" + logFormat, arg}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+
+	ctx, cancel := c.context(ctx, args[0])
+	defer cancel()
+
+	cmd := c.command(ctx, args...)
+
+	var stderr bytes.Buffer
+
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	commits, parseErr := parseLog(stdout)
+
+	// the remaining output has to be consumed before waiting for the process
+	_, _ = io.Copy(io.Discard, stdout)
+
+	err = cmd.Wait()
+	c.l.Debugw("git command", "args", strings.Join(cmd.Args, " "), "commits", len(commits), "err", err)
+
+	if err := c.contextErr(ctx, args); err != nil {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, errors.New(stderr.String())
+	}
+
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse git log output: %w", parseErr)
+	}
+
+	return commits, nil
+}
+
+// logFormat is the git log format of a commit: hash, author name, author
+// email, author date, committer date, parents and message. The fields are
+// separated by NUL bytes. With -z the commits are separated by NUL bytes too.
+const logFormat = "%H%x00%an%x00%ae%x00%at%x00%ct%x00%P%x00%B"
+
+// logFields is the number of fields in logFormat.
+const logFields = 7
+
+// parseLog parses the output of git log -z with logFormat.
+func parseLog(r io.Reader) ([]Commit, error) {
+	br := bufio.NewReader(r)
+	commits := []Commit{}
+	fields := make([]string, 0, logFields)
+
+	for {
+		field, err := br.ReadString(0)
+		if err == io.EOF {
+			if field == "" && len(fields) == 0 {
+				return commits, nil
+			}
+
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, strings.TrimSuffix(field, "\x00"))
+		if len(fields) < logFields {
+			continue
+		}
+
+		commit, err := newCommit(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, commit)
+		fields = fields[:0]
+	}
+}
+
+func newCommit(fields []string) (Commit, error) {
+	authorDate, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return Commit{}, fmt.Errorf("invalid author date '%s' of commit %s: %w", fields[3], fields[0], err)
+	}
+
+	commitDate, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return Commit{}, fmt.Errorf("invalid commit date '%s' of commit %s: %w", fields[4], fields[0], err)
+	}
+
+	return Commit{
+		Revision:   fields[0],
+		Author:     fields[1],
+		Email:      fields[2],
+		AuthorDate: time.Unix(authorDate, 0),
+		CommitDate: time.Unix(commitDate, 0),
+		Parents:    strings.Fields(fields[5]),
+		Message:    strings.TrimSpace(fields[6]),
+	}, nil
+}
+
+// Commit represents a commit with its message, author, dates and parents.
+type Commit struct {
+	Message    string
+	Revision   string
+	Author     string
+	Email      string
+	AuthorDate time.Time
+	CommitDate time.Time
+	Parents    []string
+}
+
+func clean(output string, err error) (string, error) {
+	output = strings.ReplaceAll(strings.Split(output, "\n")[0], "'", "")
+
+	if err != nil {
+		err = errors.New(strings.TrimSuffix(err.Error(), "\n"))
+	}
+
+	return output, err
+}
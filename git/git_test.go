@@ -0,0 +1,566 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/postfinance/flash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagFormat(t *testing.T) {
+	var tt = []struct {
+		name     string
+		format   TagFormat
+		version  string
+		tag      string
+		others   []string // tags that must not match
+		expected string   // expected version of tag
+	}{
+		{
+			"default",
+			TagFormat{},
+			"1.2.0",
+			"v1.2.0",
+			[]string{"latest", "deploy-prod", "api/v1.2.0", "vx1.2.0"},
+			"1.2.0",
+		},
+		{
+			"prefix without v",
+			TagFormat{Prefix: "release-", OmitV: true},
+			"1.2.0",
+			"release-1.2.0",
+			[]string{"v1.2.0", "release-", "release-candidate"},
+			"1.2.0",
+		},
+		{
+			"component",
+			TagFormat{Component: "api"},
+			"1.2.0-rc.1",
+			"api/v1.2.0-rc.1",
+			[]string{"v1.2.0", "web/v1.2.0", "api/x1.2.0"},
+			"1.2.0-rc.1",
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.tag, tc.format.Name(tc.version))
+
+			tag, ok := tc.format.Parse(tc.tag)
+			assert.True(t, ok)
+			assert.Equal(t, tc.expected, tag.Version.String())
+
+			for _, o := range tc.others {
+				_, ok := tc.format.Parse(o)
+				assert.False(t, ok, o)
+			}
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, skipped := TagFormat{}.parse("v1.4.0-rc.1\nlatest\nv1.3.0\nv1.4.0\nv1.4.0-rc.2\nv1.10.0\n")
+
+	names := []string{}
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+
+	assert.Equal(t, []string{"v1.10.0", "v1.4.0", "v1.4.0-rc.2", "v1.4.0-rc.1", "v1.3.0"}, names)
+	assert.Equal(t, []skippedTag{{name: "latest", reason: "no semantic version"}}, skipped)
+
+	f := TagFormat{
+		Include: []string{"v1.*"},
+		Exclude: []string{"*-rc.*"},
+	}
+	tags, skipped = f.parse("v1.4.0-rc.1\nv2.0.0\nv1.3.0\nvdeploy-prod\n")
+
+	names = []string{}
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+
+	assert.Equal(t, []string{"v1.3.0"}, names)
+	assert.Equal(t, []skippedTag{
+		{name: "v1.4.0-rc.1", reason: "excluded"},
+		{name: "v2.0.0", reason: "not included"},
+		{name: "vdeploy-prod", reason: "not included"},
+	}, skipped)
+}
+
+func TestParseLog(t *testing.T) {
+	out := "a1\x00Jane Doe\x00jane@example.com\x001609459200\x001609462800\x00b2 c3\x00Merge branch 'x'\n\x00" +
+		"b2\x00John Doe\x00john@example.com\x001609372800\x001609372800\x00\x00feat: add feature\n\nwith body\n\x00"
+
+	commits, err := parseLog(strings.NewReader(out))
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+
+	assert.Equal(t, Commit{
+		Revision:   "a1",
+		Author:     "Jane Doe",
+		Email:      "jane@example.com",
+		AuthorDate: time.Unix(1609459200, 0),
+		CommitDate: time.Unix(1609462800, 0),
+		Parents:    []string{"b2", "c3"},
+		Message:    "Merge branch 'x'",
+	}, commits[0])
+	assert.Equal(t, "feat: add feature\n\nwith body", commits[1].Message)
+	assert.Empty(t, commits[1].Parents)
+
+	_, err = parseLog(strings.NewReader("a1\x00Jane Doe\x00"))
+	assert.Error(t, err)
+
+	_, err = parseLog(strings.NewReader("a1\x00Jane Doe\x00jane@example.com\x00now\x001609462800\x00\x00msg\x00"))
+	assert.Error(t, err)
+}
+
+// BenchmarkLog compares reading commits with one git show process per
+// revision and with a single git log process.
+func BenchmarkLog(b *testing.B) {
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "cc-bench")
+	require.NoError(b, err)
+
+	defer os.RemoveAll(dir)
+
+	old, err := os.Getwd()
+	require.NoError(b, err)
+
+	require.NoError(b, os.Chdir(dir))
+
+	defer func() {
+		require.NoError(b, os.Chdir(old))
+	}()
+
+	createRepo(b, 200)
+
+	c := Command{l: flash.New()}
+
+	// one git show process per commit
+	b.Run("Show", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			out, err := c.Run("rev-list", "master")
+			require.NoError(b, err)
+
+			revs := strings.Fields(out)
+
+			for _, r := range revs {
+				_, err := c.Run("show", "--format=%B", "-s", r)
+				require.NoError(b, err)
+			}
+		}
+	})
+
+	b.Run("Log", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := c.Log(ctx, "", "master")
+			require.NoError(b, err)
+		}
+	})
+}
+
+// createRepo creates a git repository with n commits in the current directory.
+func createRepo(b *testing.B, n int) {
+	var stream strings.Builder
+
+	for i := 0; i < n; i++ {
+		msg := fmt.Sprintf("feat: add feature %d", i)
+		fmt.Fprintf(&stream, "commit refs/heads/master\ncommitter test <test@example.com> %d +0000\ndata %d\n%s\n", 1609459200+i, len(msg), msg)
+	}
+
+	for _, args := range [][]string{{"init", "-q"}, {"fast-import", "--quiet"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Stdin = strings.NewReader(stream.String())
+
+		out, err := cmd.CombinedOutput()
+		require.NoError(b, err, string(out))
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+
+	m := NewMemory("/repo")
+
+	first := m.AddCommit("feat: initial version", "main.go")
+	require.NoError(t, m.AddTag("v0.1.0", first))
+	require.NoError(t, m.AddTag("latest", "HEAD"))
+
+	api := m.AddCommit("feat(api): add endpoint", "services/api/main.go")
+	m.AddCommit("fix(web): fix layout", "services/web/index.html")
+
+	commits, err := m.Log(ctx, "tags/v0.1.0", "HEAD")
+	require.NoError(t, err)
+	assert.Len(t, commits, 2)
+
+	commits, err = m.Log(ctx, "v0.1.0", "HEAD", "/repo/services/api")
+	require.NoError(t, err)
+	require.Len(t, commits, 1)
+	assert.Equal(t, api, commits[0].Revision)
+
+	commits, err = m.Log(ctx, "", first)
+	require.NoError(t, err)
+	require.Len(t, commits, 1)
+	assert.Equal(t, first, commits[0].Revision)
+
+	tags, err := m.ListTags(ctx)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "v0.1.0", tags[0].Name)
+
+	api2 := m.WithTagFormat(TagFormat{Component: "api"})
+	require.NoError(t, api2.CreateRelease(ctx, "0.1.0", "chore: bump version to 0.1.0"))
+
+	// the copy shares the history, but not the tag format
+	tags, err = m.ListTags(ctx)
+	require.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	tags, err = api2.MergedTags(ctx, "HEAD")
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "api/v0.1.0", tags[0].Name)
+
+	tags, err = api2.MergedTags(ctx, first)
+	require.NoError(t, err)
+	assert.Len(t, tags, 0)
+
+	assert.False(t, m.IsStaged(ctx, "CHANGELOG.md"))
+	assert.Error(t, m.CommitFiles(ctx, "chore: update changelog", "CHANGELOG.md"))
+	require.NoError(t, m.StageFile(ctx, "/repo/CHANGELOG.md"))
+	require.NoError(t, m.CommitFiles(ctx, "chore: update changelog", "CHANGELOG.md"))
+	assert.Error(t, m.CreateRelease(ctx, "0.1.0", "chore: bump version to 0.1.0"))
+
+	_, err = m.Log(ctx, "", "unknown")
+	assert.Error(t, err)
+}
+
+func TestNative(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "cc-native")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	old, err := os.Getwd()
+	require.NoError(t, err)
+
+	require.NoError(t, os.Chdir(dir))
+
+	defer func() {
+		require.NoError(t, os.Chdir(old))
+	}()
+
+	date := 1609459200
+	gitRun := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Env = append(os.Environ(), fmt.Sprintf("GIT_AUTHOR_DATE=%d +0100", date), fmt.Sprintf("GIT_COMMITTER_DATE=%d +0100", date))
+		date += 3600
+
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	commit := func(file, msg string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o750))
+		require.NoError(t, os.WriteFile(file, []byte(msg), 0o600))
+		gitRun("add", file)
+		gitRun("commit", "-q", "-m", msg)
+	}
+
+	gitRun("init", "-q")
+	commit("main.go", "feat: initial version")
+	gitRun("tag", "v0.1.0")
+	commit("api/api.go", "feat(api): add endpoint")
+	gitRun("checkout", "-q", "-b", "side")
+	commit("web/index.html", "feat(web): add page")
+	gitRun("checkout", "-q", "-")
+	commit("api/api.go", "fix(api): fix endpoint\n\nwith body")
+	gitRun("merge", "-q", "--no-ff", "side", "-m", "Merge branch 'side'")
+	gitRun("tag", "-a", "v0.2.0", "-m", "release v0.2.0")
+	gitRun("tag", "deploy-prod", "HEAD~1")
+	commit("main.go", "fix: fix main")
+
+	c := Command{l: flash.New()}
+
+	compare := func(t *testing.T) {
+		n, err := Open(filepath.Join(dir, "api"), flash.New())
+		require.NoError(t, err)
+
+		defer n.Close()
+
+		topLevelDir, err := n.TopLevelDir(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Base(dir), filepath.Base(topLevelDir))
+
+		for _, args := range [][]string{
+			{"", "HEAD"},
+			{"v0.1.0", "HEAD"},
+			{"tags/v0.2.0", "HEAD"},
+			{"", "v0.2.0"},
+			{"", "HEAD", "api"},
+			{"", "HEAD", "web", "main.go"},
+			{"v0.1.0", "HEAD", filepath.Join(dir, "web")},
+		} {
+			expected, err := c.Log(ctx, args[0], args[1], args[2:]...)
+			require.NoError(t, err)
+
+			actual, err := n.Log(ctx, args[0], args[1], args[2:]...)
+			require.NoError(t, err)
+
+			assert.Equal(t, expected, actual, args)
+		}
+
+		expectedTags, err := c.ListTags(ctx)
+		require.NoError(t, err)
+
+		actualTags, err := n.ListTags(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, expectedTags, actualTags)
+
+		mergedTags, err := n.MergedTags(ctx, "side")
+		require.NoError(t, err)
+		require.Len(t, mergedTags, 1)
+		assert.Equal(t, "v0.1.0", mergedTags[0].Name)
+
+		for _, tag := range []string{"v0.1.0", "v0.2.0"} {
+			expected, err := c.TagDate(ctx, tag)
+			require.NoError(t, err)
+
+			actual, err := n.TagDate(ctx, tag)
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+
+		assert.False(t, n.HasRemote(ctx, "origin"))
+		assert.Equal(t, ErrReadOnly, n.CreateRelease(ctx, "0.3.0", "chore: bump version to 0.3.0"))
+
+		_, err = n.Log(ctx, "", "unknown")
+		assert.Error(t, err)
+	}
+
+	t.Run("loose objects", compare)
+
+	gitRun("gc", "-q", "--aggressive")
+
+	t.Run("pack files", compare)
+
+	t.Run("shallow clone", func(t *testing.T) {
+		shallow := filepath.Join(dir, "shallow")
+		gitRun("clone", "-q", "--depth", "2", "file://"+filepath.ToSlash(dir), shallow)
+
+		n, err := Open(shallow, flash.New())
+		require.NoError(t, err)
+
+		defer n.Close()
+
+		commits, err := n.Log(ctx, "", "HEAD")
+		require.NoError(t, err)
+		require.Len(t, commits, 2)
+		assert.Equal(t, "fix: fix main", commits[0].Message)
+		assert.Empty(t, commits[1].Parents)
+
+		commits, err = n.Log(ctx, "", "HEAD", filepath.Join(shallow, "api"))
+		require.NoError(t, err)
+		assert.Len(t, commits, 1)
+	})
+}
+
+func TestRunContext(t *testing.T) {
+	c := Command{l: flash.New(), Timeout: time.Nanosecond}
+
+	_, err := c.RunContext(context.Background(), "version")
+	assert.EqualError(t, err, "git version: timeout after 1ns")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c.Timeout = 0
+
+	_, err = c.RunContext(ctx, "version")
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	out, err := c.RunContext(context.Background(), "version")
+	require.NoError(t, err)
+	assert.Contains(t, out, "git version")
+
+	// the timeout of network commands is configured separately
+	c.Timeout = time.Nanosecond
+	assert.Equal(t, time.Duration(0), c.timeout("push"))
+	assert.Equal(t, time.Nanosecond, c.timeout("log"))
+
+	c.NetworkTimeout = time.Minute
+	assert.Equal(t, time.Minute, c.timeout("fetch"))
+}
+
+func TestNoPrompt(t *testing.T) {
+	t.Setenv("GIT_SSH_COMMAND", "")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_COUNT", "0")
+
+	ctx := context.Background()
+
+	c, err := New(flash.New())
+	require.NoError(t, err)
+
+	c.NoPrompt = true
+
+	env := c.command(ctx, "version").Env
+	assert.Contains(t, env, "GIT_TERMINAL_PROMPT=0")
+	assert.Contains(t, env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+
+	t.Run("core.sshCommand", func(t *testing.T) {
+		t.Setenv("GIT_CONFIG_COUNT", "1")
+		t.Setenv("GIT_CONFIG_KEY_0", "core.sshCommand")
+		t.Setenv("GIT_CONFIG_VALUE_0", "ssh -i deploy_key")
+
+		// core.sshCommand is read once by New
+		c, err := New(flash.New())
+		require.NoError(t, err)
+
+		c.NoPrompt = true
+
+		env := c.command(ctx, "version").Env
+		assert.Contains(t, env, "GIT_TERMINAL_PROMPT=0")
+		assert.NotContains(t, env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	})
+
+	c.NoPrompt = false
+	assert.Nil(t, c.command(ctx, "version").Env)
+}
+
+func TestPush(t *testing.T) {
+	assert.Equal(t, "git push --follow-tags origin", strings.Join(pushArgs(PushOptions{Remote: "origin"}), " "))
+	assert.Equal(t, "git push --atomic upstream HEAD:refs/heads/main refs/tags/v1.0.0", strings.Join(pushArgs(PushOptions{
+		Remote: "upstream",
+		Refs:   []string{"HEAD:refs/heads/main", "refs/tags/v1.0.0"},
+		Atomic: true,
+	}), " "))
+
+	ctx := context.Background()
+
+	m := NewMemory("/repo")
+	m.AddCommit("feat: initial version", "main.go")
+	require.NoError(t, m.AddTag("v0.1.0", "HEAD"))
+	require.NoError(t, m.AddTag("v0.2.0", "HEAD"))
+
+	assert.Error(t, m.Push(ctx, PushOptions{Remote: "upstream"}))
+
+	require.NoError(t, m.Push(ctx, PushOptions{Remote: "origin", Refs: []string{"refs/tags/v0.2.0"}}))
+
+	for name, remote := range map[string]bool{"v0.1.0": false, "v0.2.0": true} {
+		exists, err := m.RemoteTagExists(ctx, "origin", name)
+		require.NoError(t, err)
+		assert.Equal(t, remote, exists, name)
+	}
+
+	assert.Equal(t, 1, m.Pushes())
+	assert.Equal(t, []string{"refs/tags/v0.2.0"}, m.LastPush().Refs)
+}
+
+func TestSigning(t *testing.T) {
+	assert.Equal(t, []string{"-a"}, tagSignArgs(Signing{Commit: true}))
+	assert.Equal(t, []string{"-s"}, tagSignArgs(Signing{Tag: true}))
+	assert.Equal(t, []string{"-u", "ABCD1234"}, tagSignArgs(Signing{Tag: true, Key: "ABCD1234"}))
+
+	ctx := context.Background()
+
+	m := NewMemory("/repo")
+	m.AddCommit("feat: initial version", "main.go")
+	require.NoError(t, m.AddTag("v0.1.0", "HEAD"))
+	require.NoError(t, m.StageFile(ctx, "CHANGELOG.md"))
+
+	s := m.WithSigning(Signing{Commit: true, Tag: true})
+	require.NoError(t, s.CommitFiles(ctx, "chore: update changelog", "CHANGELOG.md"))
+	require.NoError(t, s.CreateRelease(ctx, "0.2.0", "chore: bump version to 0.2.0"))
+
+	commits, err := m.Log(ctx, "", "HEAD")
+	require.NoError(t, err)
+	assert.True(t, m.IsSigned(commits[0].Revision))
+	assert.False(t, m.IsSigned(commits[1].Revision))
+
+	assert.NoError(t, m.VerifyTag(ctx, "v0.2.0"))
+	assert.EqualError(t, m.VerifyTag(ctx, "v0.1.0"), "signature of tag v0.1.0: no signature found")
+
+	require.NoError(t, m.DeleteRelease(ctx, "0.2.0"))
+	assert.False(t, m.IsSigned("v0.2.0"))
+}
+
+func TestCommit(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "cc-messages")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	old, err := os.Getwd()
+	require.NoError(t, err)
+
+	require.NoError(t, os.Chdir(dir))
+
+	defer func() {
+		require.NoError(t, os.Chdir(old))
+	}()
+
+	c := Command{l: flash.New()}
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "test"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "tag.gpgSign", "false"},
+		{"config", "commit.gpgSign", "false"},
+	} {
+		_, err := c.RunContext(ctx, args...)
+		require.NoError(t, err)
+	}
+
+	msg := "v0.2.0\n\n## 0.2.0 (2021-01-01)\n\n### New Features\n\n* add feature"
+
+	require.NoError(t, os.WriteFile("CHANGELOG.md", []byte(msg), 0o600))
+	require.NoError(t, c.StageFile(ctx, "CHANGELOG.md"))
+	require.NoError(t, c.CommitFiles(ctx, "chore: release 0.2.0\n\n# not a comment", "CHANGELOG.md"))
+	require.NoError(t, c.CreateRelease(ctx, "0.2.0", msg))
+
+	out, err := c.RunContext(ctx, "cat-file", "-p", "v0.2.0")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(out), msg), out)
+
+	out, err = c.RunContext(ctx, "log", "-1", "--format=%B")
+	require.NoError(t, err)
+	assert.Equal(t, "chore: release 0.2.0\n\n# not a comment", strings.TrimSpace(out))
+
+	// changed files are restored from HEAD
+	require.NoError(t, os.WriteFile("CHANGELOG.md", []byte("changed"), 0o600))
+	require.NoError(t, os.WriteFile("untracked.md", []byte("new"), 0o600))
+
+	changed, err := c.ChangedFiles(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CHANGELOG.md"}, changed)
+
+	require.NoError(t, c.StageFile(ctx, "CHANGELOG.md"))
+	require.NoError(t, c.RestoreFile(ctx, "CHANGELOG.md"))
+
+	b, err := os.ReadFile("CHANGELOG.md")
+	require.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+
+	changed, err = c.ChangedFiles(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+}
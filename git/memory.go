@@ -0,0 +1,616 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" // nolint: gosec
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Memory is an in-memory repository with a linear history. It is intended
+// for testing the release flows with synthetic histories. The context of the
+// operations is ignored. Copies created with WithTagFormat share the history
+// with the original.
+type Memory struct {
+	TagFormat TagFormat // the format of release tags
+	Signing   Signing   // the signing of release commits and tags
+	state     *memoryState
+}
+
+type memoryState struct {
+	dir         string
+	remote      string // the name of the remote, empty if there is none
+	uncommitted bool
+	pushes      []PushOptions
+	commits     []Commit // oldest first
+	files       [][]string
+	contents    []map[string][]byte // per commit: the content of its files, missing files are omitted
+	tags        map[string]int      // tag name -> index of commit
+	tracked     map[string]bool
+	failures    map[string]error // operation -> error
+	branch      string
+	sync        SyncStatus
+	remoteTags  map[string]bool
+	remoteHeads map[string]string // branch name -> revision on the remote
+	signed      map[string]bool   // commit revisions and tag refs with a signature
+	messages    map[string]string // tag name -> message of annotated tags
+}
+
+// NewMemory creates an empty in-memory repository with an origin remote. The
+// top level directory of the repository is dir.
+func NewMemory(dir string) *Memory {
+	return &Memory{
+		state: &memoryState{
+			dir:         dir,
+			remote:      "origin",
+			tags:        map[string]int{},
+			tracked:     map[string]bool{},
+			failures:    map[string]error{},
+			branch:      "main",
+			remoteTags:  map[string]bool{},
+			remoteHeads: map[string]string{},
+			signed:      map[string]bool{},
+			messages:    map[string]string{},
+		},
+	}
+}
+
+// AddCommit adds a commit touching files on top of HEAD and returns its
+// revision.
+func (m *Memory) AddCommit(msg string, files ...string) string {
+	s := m.state
+	n := len(s.commits)
+	date := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(n) * time.Hour)
+
+	c := Commit{
+		Revision:   fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("%d\n%s", n, msg)))), // nolint: gosec
+		Message:    strings.TrimSpace(msg),
+		Author:     "test",
+		Email:      "test@example.com",
+		AuthorDate: date,
+		CommitDate: date,
+	}
+
+	if n > 0 {
+		c.Parents = []string{s.commits[n-1].Revision}
+	}
+
+	for _, f := range files {
+		s.tracked[m.rel(f)] = true
+	}
+
+	contents := map[string][]byte{}
+
+	for _, f := range files {
+		if b, err := os.ReadFile(filepath.Join(s.dir, m.rel(f))); err == nil {
+			contents[m.rel(f)] = b
+		}
+	}
+
+	s.commits = append(s.commits, c)
+	s.files = append(s.files, files)
+	s.contents = append(s.contents, contents)
+
+	return c.Revision
+}
+
+// AddTag adds a tag with name to revision.
+func (m *Memory) AddTag(name, revision string) error {
+	if _, ok := m.state.tags[name]; ok {
+		return fmt.Errorf("tag '%s' already exists", name)
+	}
+
+	i, err := m.resolve(revision)
+	if err != nil {
+		return err
+	}
+
+	m.state.tags[name] = i
+
+	return nil
+}
+
+// SetRemote configures the name of the remote. An empty name removes the
+// remote.
+func (m *Memory) SetRemote(remote string) {
+	m.state.remote = remote
+}
+
+// SetUncommitted configures if the repository has uncommitted changes.
+func (m *Memory) SetUncommitted(uncommitted bool) {
+	m.state.uncommitted = uncommitted
+}
+
+// Fail configures operation to fail with err. Supported operations are
+// stage, commit, tag and push. The operation push-tags fails a push after the
+// branch has been updated on the remote. If err is nil, the operation succeeds
+// again.
+func (m *Memory) Fail(operation string, err error) {
+	m.state.failures[operation] = err
+}
+
+// SetBranch sets the name of the current branch. An empty name means a
+// detached HEAD.
+func (m *Memory) SetBranch(name string) {
+	m.state.branch = name
+}
+
+// SetSyncStatus sets the state of the current branch compared to its
+// upstream.
+func (m *Memory) SetSyncStatus(s SyncStatus) {
+	m.state.sync = s
+}
+
+// AddRemoteTag adds a tag that only exists on the remote.
+func (m *Memory) AddRemoteTag(name string) {
+	m.state.remoteTags[name] = true
+}
+
+// Pushes returns the number of pushes.
+func (m *Memory) Pushes() int {
+	return len(m.state.pushes)
+}
+
+// LastPush returns the options of the last push.
+func (m *Memory) LastPush() PushOptions {
+	if len(m.state.pushes) == 0 {
+		return PushOptions{}
+	}
+
+	return m.state.pushes[len(m.state.pushes)-1]
+}
+
+// IsRepo returns always true.
+func (m *Memory) IsRepo(ctx context.Context) bool {
+	return true
+}
+
+// TopLevelDir returns the directory the repository has been created with.
+func (m *Memory) TopLevelDir(ctx context.Context) (string, error) {
+	return m.state.dir, nil
+}
+
+// HasRemote returns true if the remote name is configured.
+func (m *Memory) HasRemote(ctx context.Context, name string) bool {
+	return m.state.remote != "" && m.state.remote == name
+}
+
+// HasUncommitted returns true if there are uncommitted changes.
+func (m *Memory) HasUncommitted(ctx context.Context) (bool, error) {
+	return m.state.uncommitted, nil
+}
+
+// IsStaged returns true if path is tracked.
+func (m *Memory) IsStaged(ctx context.Context, path string) bool {
+	return m.state.tracked[m.rel(path)]
+}
+
+// WithTagFormat returns a copy of m with the tag format f.
+func (m *Memory) WithTagFormat(f TagFormat) Repository {
+	c := *m
+	c.TagFormat = f
+
+	return &c
+}
+
+// WithSigning returns a copy of m with the signing configuration s.
+func (m *Memory) WithSigning(s Signing) Repository {
+	c := *m
+	c.Signing = s
+
+	return &c
+}
+
+// IsSigned returns true if the commit revision or the tag name has been
+// created with a signature.
+func (m *Memory) IsSigned(revOrTag string) bool {
+	return m.state.signed[revOrTag] || m.state.signed["refs/tags/"+revOrTag]
+}
+
+// TagMessage returns the message of the annotated tag name. For lightweight
+// tags an empty string is returned.
+func (m *Memory) TagMessage(name string) string {
+	return m.state.messages[name]
+}
+
+// VerifyTag returns an error if the tag name has no signature.
+func (m *Memory) VerifyTag(ctx context.Context, name string) error {
+	if _, ok := m.state.tags[name]; !ok {
+		return fmt.Errorf("tag '%s' not found", name)
+	}
+
+	if !m.state.signed["refs/tags/"+name] {
+		return fmt.Errorf("signature of tag %s: no signature found", name)
+	}
+
+	return nil
+}
+
+// TagName returns the name of the release tag for version.
+func (m *Memory) TagName(version string) string {
+	return m.TagFormat.Name(version)
+}
+
+// ListTags returns all release tags sorted by semantic version in descending
+// order.
+func (m *Memory) ListTags(ctx context.Context) (Tags, error) {
+	return m.MergedTags(ctx, "")
+}
+
+// MergedTags returns all release tags reachable from revision sorted by
+// semantic version in descending order. If revision is empty, all release
+// tags are returned.
+func (m *Memory) MergedTags(ctx context.Context, revision string) (Tags, error) {
+	head := len(m.state.commits) - 1
+
+	if revision != "" {
+		i, err := m.resolve(revision)
+		if err != nil {
+			return nil, err
+		}
+
+		head = i
+	}
+
+	names := []string{}
+
+	for name, i := range m.state.tags {
+		if i <= head {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	tags, _ := m.TagFormat.parse(strings.Join(names, "\n"))
+
+	return tags, nil
+}
+
+// HasTags returns true if the repository has release tags.
+func (m *Memory) HasTags(ctx context.Context) (bool, error) {
+	tags, err := m.ListTags(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return len(tags) > 0, nil
+}
+
+// TagDate returns the date of the commit of tag.
+func (m *Memory) TagDate(ctx context.Context, tag string) (string, error) {
+	if tag == "" {
+		return "", errors.New("tag cannot be empty")
+	}
+
+	i, err := m.resolve(tag)
+	if err != nil {
+		return "", err
+	}
+
+	return m.state.commits[i].CommitDate.Format("2006-01-02"), nil
+}
+
+// Log returns the commits start..end (newest first). If start is empty, all
+// commits reachable from end are returned. If paths are given, only commits
+// touching these paths are returned.
+func (m *Memory) Log(ctx context.Context, start, end string, paths ...string) ([]Commit, error) {
+	last, err := m.resolve(end)
+	if err != nil {
+		return nil, err
+	}
+
+	first := -1
+
+	if start != "" {
+		first, err = m.resolve(start)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commits := []Commit{}
+
+	for i := last; i > first; i-- {
+		if len(paths) == 0 || m.touches(i, paths) {
+			commits = append(commits, m.state.commits[i])
+		}
+	}
+
+	return commits, nil
+}
+
+// StageFile tracks file.
+func (m *Memory) StageFile(ctx context.Context, file string) error {
+	if err := m.state.failures["stage"]; err != nil {
+		return err
+	}
+
+	m.state.tracked[m.rel(file)] = true
+
+	return nil
+}
+
+// UnstageFile untracks file.
+func (m *Memory) UnstageFile(ctx context.Context, file string) error {
+	delete(m.state.tracked, m.rel(file))
+	return nil
+}
+
+// ChangedFiles returns the tracked files whose content in the top level
+// directory differs from their content in the last commit touching them.
+func (m *Memory) ChangedFiles(ctx context.Context) ([]string, error) {
+	files := []string{}
+
+	for f := range m.state.tracked {
+		committed, ok := m.committed(f)
+		b, err := os.ReadFile(filepath.Join(m.state.dir, f))
+
+		if ok != (err == nil) || (ok && !bytes.Equal(committed, b)) {
+			files = append(files, f)
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// RestoreFile restores the content of file from the last commit touching it.
+// Files that are not committed are removed and untracked.
+func (m *Memory) RestoreFile(ctx context.Context, file string) error {
+	f := m.rel(file)
+	p := filepath.Join(m.state.dir, f)
+
+	committed, ok := m.committed(f)
+	if !ok {
+		delete(m.state.tracked, f)
+		return os.Remove(p)
+	}
+
+	return os.WriteFile(p, committed, 0o600)
+}
+
+// committed returns the content of the file f in the last commit touching it.
+func (m *Memory) committed(f string) ([]byte, bool) {
+	s := m.state
+
+	for i := len(s.commits) - 1; i >= 0; i-- {
+		for _, cf := range s.files[i] {
+			if m.rel(cf) == f {
+				b, ok := s.contents[i][f]
+				return b, ok
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// ResetCommit removes the last commit. Files that are not part of an older
+// commit are untracked.
+func (m *Memory) ResetCommit(ctx context.Context) error {
+	s := m.state
+	n := len(s.commits)
+
+	if n < 2 {
+		return errors.New("ambiguous argument 'HEAD~1': unknown revision")
+	}
+
+	files := s.files[n-1]
+	s.commits, s.files, s.contents = s.commits[:n-1], s.files[:n-1], s.contents[:n-1]
+
+	for _, f := range files {
+		delete(s.tracked, m.rel(f))
+	}
+
+	for _, l := range s.files {
+		for _, f := range l {
+			s.tracked[m.rel(f)] = true
+		}
+	}
+
+	return nil
+}
+
+// DeleteRelease deletes the release tag of version.
+func (m *Memory) DeleteRelease(ctx context.Context, version string) error {
+	name := m.TagFormat.Name(version)
+
+	if _, ok := m.state.tags[name]; !ok {
+		return fmt.Errorf("tag '%s' not found", name)
+	}
+
+	delete(m.state.tags, name)
+	delete(m.state.signed, "refs/tags/"+name)
+	delete(m.state.messages, name)
+
+	return nil
+}
+
+// CommitFiles adds a commit touching files on top of HEAD. All changes are
+// committed afterwards.
+func (m *Memory) CommitFiles(ctx context.Context, msg string, files ...string) error {
+	if err := m.state.failures["commit"]; err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if !m.IsStaged(ctx, f) {
+			return fmt.Errorf("pathspec '%s' did not match any file(s) known to git", f)
+		}
+	}
+
+	rev := m.AddCommit(msg, files...)
+	m.state.uncommitted = false
+	m.state.signed[rev] = m.Signing.Commit
+
+	return nil
+}
+
+// CreateRelease creates the annotated release tag for version on HEAD.
+func (m *Memory) CreateRelease(ctx context.Context, version, msg string) error {
+	if err := m.state.failures["tag"]; err != nil {
+		return err
+	}
+
+	name := m.TagFormat.Name(version)
+
+	if err := m.AddTag(name, "HEAD"); err != nil {
+		return err
+	}
+
+	m.state.signed["refs/tags/"+name] = m.Signing.Tag
+	m.state.messages[name] = msg
+
+	return nil
+}
+
+// Push marks the pushed tags as remote tags and records the push. Without refs
+// all tags are pushed.
+func (m *Memory) Push(ctx context.Context, opts PushOptions) error {
+	if err := m.state.failures["push"]; err != nil {
+		return err
+	}
+
+	if !m.HasRemote(ctx, opts.Remote) {
+		return fmt.Errorf("'%s' does not appear to be a git repository", opts.Remote)
+	}
+
+	if n := len(m.state.commits); n > 0 && m.state.branch != "" {
+		m.state.remoteHeads[m.state.branch] = m.state.commits[n-1].Revision
+	}
+
+	if err := m.state.failures["push-tags"]; err != nil {
+		return err
+	}
+
+	for name := range m.state.tags {
+		if len(opts.Refs) == 0 {
+			m.state.remoteTags[name] = true
+			continue
+		}
+
+		for _, r := range opts.Refs {
+			if r == "refs/tags/"+name {
+				m.state.remoteTags[name] = true
+			}
+		}
+	}
+
+	m.state.pushes = append(m.state.pushes, opts)
+
+	return nil
+}
+
+// CurrentBranch returns the name of the current branch.
+func (m *Memory) CurrentBranch(ctx context.Context) (string, error) {
+	return m.state.branch, nil
+}
+
+// SyncStatus returns the configured sync status.
+func (m *Memory) SyncStatus(ctx context.Context) (SyncStatus, error) {
+	return m.state.sync, nil
+}
+
+// TagExists returns true if the tag name exists.
+func (m *Memory) TagExists(ctx context.Context, name string) (bool, error) {
+	_, ok := m.state.tags[name]
+	return ok, nil
+}
+
+// RemoteTagExists returns true if the tag name has been pushed or added with
+// AddRemoteTag. The memory repository has only one remote.
+func (m *Memory) RemoteTagExists(ctx context.Context, remote, name string) (bool, error) {
+	return m.state.remoteTags[name], nil
+}
+
+// RemoteBranchAt returns true if branch has been pushed at revision. The
+// memory repository has only one remote.
+func (m *Memory) RemoteBranchAt(ctx context.Context, remote, branch, revision string) (bool, error) {
+	i, err := m.resolve(revision)
+	if err != nil {
+		return false, err
+	}
+
+	return m.state.remoteHeads[branch] == m.state.commits[i].Revision, nil
+}
+
+// PointsAt returns the release tags pointing at revision.
+func (m *Memory) PointsAt(ctx context.Context, revision string) (Tags, error) {
+	i, err := m.resolve(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+
+	for name, j := range m.state.tags {
+		if i == j {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	tags, _ := m.TagFormat.parse(strings.Join(names, "\n"))
+
+	return tags, nil
+}
+
+// resolve returns the index of the commit of revision. Revision can be HEAD,
+// a tag name (with or without 'tags/' prefix) or a commit hash.
+func (m *Memory) resolve(revision string) (int, error) {
+	if revision == "HEAD" {
+		if len(m.state.commits) == 0 {
+			return 0, errors.New("ambiguous argument 'HEAD': unknown revision")
+		}
+
+		return len(m.state.commits) - 1, nil
+	}
+
+	if i, ok := m.state.tags[strings.TrimPrefix(revision, "tags/")]; ok {
+		return i, nil
+	}
+
+	for i, c := range m.state.commits {
+		if c.Revision == revision {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("ambiguous argument '%s': unknown revision", revision)
+}
+
+// touches returns true if the commit with index i touches one of paths.
+func (m *Memory) touches(i int, paths []string) bool {
+	for _, f := range m.state.files[i] {
+		f = m.rel(f)
+
+		for _, p := range paths {
+			p = m.rel(p)
+			if p == "." || f == p || strings.HasPrefix(f, p+"/") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// rel returns path relative to the top level directory with forward slashes.
+func (m *Memory) rel(path string) string {
+	if filepath.IsAbs(path) {
+		if r, err := filepath.Rel(m.state.dir, path); err == nil {
+			path = r
+		}
+	}
+
+	return filepath.ToSlash(filepath.Clean(path))
+}
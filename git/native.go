@@ -0,0 +1,858 @@
+package git
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/postfinance/flash"
+)
+
+// ErrReadOnly is returned by Native for operations that change the
+// repository.
+var ErrReadOnly = errors.New("operation not supported by the read-only git backend")
+
+var hashRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Native is a read-only repository implemented in pure Go. It reads objects,
+// pack files and refs directly from the .git directory and can be used if no
+// git binary is available. All operations that change the repository return
+// ErrReadOnly.
+type Native struct {
+	TagFormat TagFormat // the format of release tags
+	l         *flash.Logger
+	state     *nativeState
+}
+
+type nativeState struct {
+	workTree  string
+	gitDir    string // the git directory of the work tree
+	commonDir string // contains objects and refs, differs from gitDir for linked work trees
+	objects   *objectStore
+	commits   map[string]*commitObject
+	packed    map[string]string // packed refs
+	shallow   map[string]bool   // the boundary commits of a shallow clone, their parents are missing
+}
+
+// Open opens the repository containing dir.
+func Open(dir string, l *flash.Logger) (*Native, error) {
+	workTree, gitDir, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	commonDir := gitDir
+
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil { // nolint: gosec
+		commonDir = strings.TrimSpace(string(b))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	objects, err := openObjectStore(filepath.Join(commonDir, "objects"))
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Native{
+		l: l,
+		state: &nativeState{
+			workTree:  workTree,
+			gitDir:    gitDir,
+			commonDir: commonDir,
+			objects:   objects,
+			commits:   map[string]*commitObject{},
+		},
+	}
+
+	if err := n.readPackedRefs(); err != nil {
+		return nil, err
+	}
+
+	if err := n.readShallow(); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// readShallow reads the boundary commits of a shallow clone.
+func (n *Native) readShallow() error {
+	n.state.shallow = map[string]bool{}
+
+	b, err := os.ReadFile(filepath.Join(n.state.commonDir, "shallow")) // nolint: gosec
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, rev := range strings.Fields(string(b)) {
+		n.state.shallow[rev] = true
+	}
+
+	return nil
+}
+
+// findGitDir returns the work tree and git directory of the repository
+// containing dir. The .git entry can be a directory or a file pointing to the
+// git directory.
+func findGitDir(dir string) (workTree, gitDir string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for {
+		p := filepath.Join(dir, ".git")
+
+		fi, err := os.Stat(p)
+		if err == nil && fi.IsDir() {
+			return dir, p, nil
+		}
+
+		if err == nil {
+			b, err := os.ReadFile(p) // nolint: gosec
+			if err != nil {
+				return "", "", err
+			}
+
+			gitDir := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(b)), "gitdir:"))
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+
+			return dir, gitDir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errors.New("not a git repository (or any of the parent directories)")
+		}
+
+		dir = parent
+	}
+}
+
+// Close closes the pack files of the repository.
+func (n *Native) Close() error {
+	return n.state.objects.close()
+}
+
+// IsRepo returns always true.
+func (n *Native) IsRepo(ctx context.Context) bool {
+	return true
+}
+
+// TopLevelDir returns the git top level directory.
+func (n *Native) TopLevelDir(ctx context.Context) (string, error) {
+	return n.state.workTree, nil
+}
+
+// HasRemote checks if the remote name is configured.
+func (n *Native) HasRemote(ctx context.Context, name string) bool {
+	f, err := os.Open(filepath.Join(n.state.commonDir, "config"))
+	if err != nil {
+		return false
+	}
+	defer f.Close() // nolint: gosec
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if strings.TrimSpace(s.Text()) == fmt.Sprintf(`[remote "%s"]`, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasUncommitted returns ErrReadOnly, because the work tree is not inspected.
+func (n *Native) HasUncommitted(ctx context.Context) (bool, error) {
+	return false, ErrReadOnly
+}
+
+// IsStaged returns always false.
+func (n *Native) IsStaged(ctx context.Context, path string) bool {
+	return false
+}
+
+// WithTagFormat returns a copy of n with the tag format f.
+func (n *Native) WithTagFormat(f TagFormat) Repository {
+	c := *n
+	c.TagFormat = f
+
+	return &c
+}
+
+// WithSigning returns n, because the read-only backend creates no commits
+// and tags.
+func (n *Native) WithSigning(s Signing) Repository {
+	return n
+}
+
+// VerifyTag returns ErrReadOnly, because signatures cannot be verified
+// without the git binary.
+func (n *Native) VerifyTag(ctx context.Context, name string) error {
+	return ErrReadOnly
+}
+
+// TagName returns the name of the release tag for version.
+func (n *Native) TagName(version string) string {
+	return n.TagFormat.Name(version)
+}
+
+// ListTags lists all release tags matching the tag format sorted by semantic
+// version in descending order.
+func (n *Native) ListTags(ctx context.Context) (Tags, error) {
+	names, err := n.tagNames()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTags(n.l, n.TagFormat, strings.Join(names, "\n")), nil
+}
+
+// MergedTags lists all release tags matching the tag format and reachable from
+// revision sorted by semantic version in descending order.
+func (n *Native) MergedTags(ctx context.Context, revision string) (Tags, error) {
+	rev, err := n.resolve(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors, err := n.ancestors(ctx, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := n.tagNames()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := []string{}
+
+	for _, name := range names {
+		rev, err := n.resolve("refs/tags/" + name)
+		if err != nil {
+			n.l.Debugw("skipping tag", "tag", name, "reason", err)
+			continue
+		}
+
+		if ancestors[rev] {
+			merged = append(merged, name)
+		}
+	}
+
+	return parseTags(n.l, n.TagFormat, strings.Join(merged, "\n")), nil
+}
+
+// HasTags returns true if repository has release tags matching the tag
+// format.
+func (n *Native) HasTags(ctx context.Context) (bool, error) {
+	tags, err := n.ListTags(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return len(tags) > 0, nil
+}
+
+// TagDate returns the author date of the commit of tag.
+func (n *Native) TagDate(ctx context.Context, tag string) (string, error) {
+	if tag == "" {
+		return "", errors.New("tag cannot be empty")
+	}
+
+	rev, err := n.resolve(tag)
+	if err != nil {
+		return "", err
+	}
+
+	c, err := n.commit(rev)
+	if err != nil {
+		return "", err
+	}
+
+	return time.Unix(c.author.when, 0).In(location(c.author.tz)).Format("2006-01-02"), nil
+}
+
+// Log returns the commits start..end ordered by commit date (newest first).
+// If start is empty, all commits reachable from end are returned. If paths
+// are given, only commits that change one of these paths compared to all of
+// their parents are returned.
+func (n *Native) Log(ctx context.Context, start, end string, paths ...string) ([]Commit, error) {
+	rev, err := n.resolve(end)
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := map[string]bool{}
+
+	if start != "" {
+		startRev, err := n.resolve(start)
+		if err != nil {
+			return nil, err
+		}
+
+		if exclude, err = n.ancestors(ctx, startRev); err != nil {
+			return nil, err
+		}
+	}
+
+	relPaths := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		relPaths = append(relPaths, n.rel(p))
+	}
+
+	commits := []Commit{}
+	seen := map[string]bool{rev: true}
+	q := &commitQueue{}
+
+	if err := n.push(q, rev); err != nil {
+		return nil, err
+	}
+
+	for q.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		item := heap.Pop(q).(queueItem)
+		if exclude[item.rev] {
+			continue
+		}
+
+		for _, p := range item.commit.parents {
+			if seen[p] {
+				continue
+			}
+
+			seen[p] = true
+
+			if err := n.push(q, p); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(relPaths) > 0 {
+			ok, err := n.touches(item.commit, relPaths)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		c := item.commit
+		commits = append(commits, Commit{
+			Revision:   item.rev,
+			Message:    strings.TrimSpace(c.message),
+			Author:     c.author.name,
+			Email:      c.author.email,
+			AuthorDate: time.Unix(c.author.when, 0),
+			CommitDate: time.Unix(c.committer.when, 0),
+			Parents:    c.parents,
+		})
+	}
+
+	n.l.Debugw("read commits", "start", start, "end", end, "paths", paths, "commits", len(commits))
+
+	return commits, nil
+}
+
+// StageFile returns ErrReadOnly.
+func (n *Native) StageFile(ctx context.Context, file string) error {
+	return ErrReadOnly
+}
+
+// CommitFiles returns ErrReadOnly.
+func (n *Native) CommitFiles(ctx context.Context, msg string, files ...string) error {
+	return ErrReadOnly
+}
+
+// CreateRelease returns ErrReadOnly.
+func (n *Native) CreateRelease(ctx context.Context, version, msg string) error {
+	return ErrReadOnly
+}
+
+// UnstageFile returns ErrReadOnly.
+func (n *Native) UnstageFile(ctx context.Context, file string) error {
+	return ErrReadOnly
+}
+
+// ChangedFiles returns ErrReadOnly, because the work tree is not inspected.
+func (n *Native) ChangedFiles(ctx context.Context) ([]string, error) {
+	return nil, ErrReadOnly
+}
+
+// RestoreFile returns ErrReadOnly.
+func (n *Native) RestoreFile(ctx context.Context, file string) error {
+	return ErrReadOnly
+}
+
+// ResetCommit returns ErrReadOnly.
+func (n *Native) ResetCommit(ctx context.Context) error {
+	return ErrReadOnly
+}
+
+// DeleteRelease returns ErrReadOnly.
+func (n *Native) DeleteRelease(ctx context.Context, version string) error {
+	return ErrReadOnly
+}
+
+// CurrentBranch returns the name of the current branch. For a detached HEAD
+// an empty string is returned.
+func (n *Native) CurrentBranch(ctx context.Context) (string, error) {
+	b, err := os.ReadFile(filepath.Join(n.state.gitDir, "HEAD")) // nolint: gosec
+	if err != nil {
+		return "", err
+	}
+
+	s := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(s, "ref:") {
+		return "", nil
+	}
+
+	return strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(s, "ref:")), "refs/heads/"), nil
+}
+
+// SyncStatus returns ErrReadOnly, because remotes cannot be fetched.
+func (n *Native) SyncStatus(ctx context.Context) (SyncStatus, error) {
+	return SyncStatus{}, ErrReadOnly
+}
+
+// TagExists returns true if the tag name exists.
+func (n *Native) TagExists(ctx context.Context, name string) (bool, error) {
+	names, err := n.tagNames()
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range names {
+		if t == name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RemoteTagExists returns ErrReadOnly, because remotes cannot be accessed.
+func (n *Native) RemoteTagExists(ctx context.Context, remote, name string) (bool, error) {
+	return false, ErrReadOnly
+}
+
+// RemoteBranchAt returns ErrReadOnly.
+func (n *Native) RemoteBranchAt(ctx context.Context, remote, branch, revision string) (bool, error) {
+	return false, ErrReadOnly
+}
+
+// PointsAt returns the release tags pointing at revision.
+func (n *Native) PointsAt(ctx context.Context, revision string) (Tags, error) {
+	rev, err := n.resolve(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := n.tagNames()
+	if err != nil {
+		return nil, err
+	}
+
+	at := []string{}
+
+	for _, name := range names {
+		if r, err := n.resolve("refs/tags/" + name); err == nil && r == rev {
+			at = append(at, name)
+		}
+	}
+
+	return parseTags(n.l, n.TagFormat, strings.Join(at, "\n")), nil
+}
+
+// Push returns ErrReadOnly.
+func (n *Native) Push(ctx context.Context, opts PushOptions) error {
+	return ErrReadOnly
+}
+
+func (n *Native) push(q *commitQueue, rev string) error {
+	c, err := n.commit(rev)
+	if err != nil {
+		return err
+	}
+
+	heap.Push(q, queueItem{rev: rev, commit: c, seq: q.seq})
+	q.seq++
+
+	return nil
+}
+
+// commit returns the commit object rev. Commits are cached. The boundary
+// commits of a shallow clone are returned without parents like git does.
+func (n *Native) commit(rev string) (*commitObject, error) {
+	if c, ok := n.state.commits[rev]; ok {
+		return c, nil
+	}
+
+	typ, data, err := n.state.objects.read(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != objCommit {
+		return nil, fmt.Errorf("object %s is not a commit", rev)
+	}
+
+	c, err := parseCommit(data)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %w", rev, err)
+	}
+
+	if n.state.shallow[rev] {
+		c.parents = []string{}
+	}
+
+	n.state.commits[rev] = c
+
+	return c, nil
+}
+
+// ancestors returns rev and all its ancestors.
+func (n *Native) ancestors(ctx context.Context, rev string) (map[string]bool, error) {
+	ancestors := map[string]bool{rev: true}
+	stack := []string{rev}
+
+	for len(stack) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		c, err := n.commit(stack[len(stack)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		stack = stack[:len(stack)-1]
+
+		for _, p := range c.parents {
+			if !ancestors[p] {
+				ancestors[p] = true
+				stack = append(stack, p)
+			}
+		}
+	}
+
+	return ancestors, nil
+}
+
+// touches returns true if one of paths differs between c and all its parents.
+func (n *Native) touches(c *commitObject, paths []string) (bool, error) {
+	for _, p := range paths {
+		entry, err := n.treeEntry(c.tree, p)
+		if err != nil {
+			return false, err
+		}
+
+		changed := true
+
+		for _, parent := range c.parents {
+			pc, err := n.commit(parent)
+			if err != nil {
+				return false, err
+			}
+
+			parentEntry, err := n.treeEntry(pc.tree, p)
+			if err != nil {
+				return false, err
+			}
+
+			if parentEntry == entry {
+				changed = false
+				break
+			}
+		}
+
+		if changed && (entry != "" || len(c.parents) > 0) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// treeEntry returns the object name of p in tree. If p does not exist, an
+// empty string is returned.
+func (n *Native) treeEntry(tree, p string) (string, error) {
+	if p == "." {
+		return tree, nil
+	}
+
+	for _, name := range strings.Split(p, "/") {
+		typ, data, err := n.state.objects.read(tree)
+		if err != nil {
+			return "", err
+		}
+
+		if typ != objTree {
+			return "", nil
+		}
+
+		entry, ok := treeEntry(data, name)
+		if !ok {
+			return "", nil
+		}
+
+		tree = entry
+	}
+
+	return tree, nil
+}
+
+// resolve returns the commit of revision. Revision can be HEAD, a commit
+// hash, a tag (with or without 'tags/' prefix), a branch or a full ref name.
+// Annotated tags are peeled.
+func (n *Native) resolve(revision string) (string, error) {
+	hash, err := n.resolveName(revision)
+	if err != nil {
+		return "", err
+	}
+
+	// peel annotated tags
+	for i := 0; i < 10; i++ {
+		typ, data, err := n.state.objects.read(hash)
+		if err != nil {
+			return "", err
+		}
+
+		switch typ {
+		case objCommit:
+			return hash, nil
+		case objTag:
+			if _, hash, err = parseTagObject(data); err != nil {
+				return "", fmt.Errorf("tag %s: %w", revision, err)
+			}
+		default:
+			return "", fmt.Errorf("'%s' does not point to a commit", revision)
+		}
+	}
+
+	return "", fmt.Errorf("too many nested tags in '%s'", revision)
+}
+
+func (n *Native) resolveName(revision string) (string, error) {
+	if hashRegex.MatchString(revision) {
+		return revision, nil
+	}
+
+	if revision == "HEAD" {
+		return n.readRef(n.state.gitDir, "HEAD", 0)
+	}
+
+	candidates := []string{"refs/" + revision, "refs/tags/" + revision, "refs/heads/" + revision, "refs/remotes/" + revision}
+	if strings.HasPrefix(revision, "refs/") {
+		candidates = []string{revision}
+	}
+
+	for _, ref := range candidates {
+		hash, err := n.readRef(n.state.commonDir, ref, 0)
+		if err == nil {
+			return hash, nil
+		}
+	}
+
+	return "", fmt.Errorf("ambiguous argument '%s': unknown revision", revision)
+}
+
+// readRef returns the object name of a loose or packed ref. Symbolic refs are
+// followed.
+func (n *Native) readRef(dir, ref string, depth int) (string, error) {
+	if depth > 5 {
+		return "", fmt.Errorf("too many levels of symbolic refs in '%s'", ref)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))) // nolint: gosec
+	if err == nil {
+		s := strings.TrimSpace(string(b))
+
+		if strings.HasPrefix(s, "ref:") {
+			return n.readRef(n.state.commonDir, strings.TrimSpace(strings.TrimPrefix(s, "ref:")), depth+1)
+		}
+
+		if !hashRegex.MatchString(s) {
+			return "", fmt.Errorf("invalid ref '%s'", ref)
+		}
+
+		return s, nil
+	}
+
+	if hash, ok := n.state.packed[ref]; ok {
+		return hash, nil
+	}
+
+	return "", fmt.Errorf("ref '%s' not found", ref)
+}
+
+func (n *Native) readPackedRefs() error {
+	n.state.packed = map[string]string{}
+
+	f, err := os.Open(filepath.Join(n.state.commonDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: gosec
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+
+		// comments and peeled values of the previous tag
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			n.state.packed[fields[1]] = fields[0]
+		}
+	}
+
+	return s.Err()
+}
+
+// tagNames returns the names of all tags.
+func (n *Native) tagNames() ([]string, error) {
+	names := map[string]bool{}
+
+	for ref := range n.state.packed {
+		if strings.HasPrefix(ref, "refs/tags/") {
+			names[strings.TrimPrefix(ref, "refs/tags/")] = true
+		}
+	}
+
+	dir := filepath.Join(n.state.commonDir, "refs", "tags")
+
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if fi.IsDir() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		names[filepath.ToSlash(name)] = true
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]string, 0, len(names))
+	for name := range names {
+		l = append(l, name)
+	}
+
+	sort.Strings(l)
+
+	return l, nil
+}
+
+// rel returns p relative to the work tree with forward slashes.
+func (n *Native) rel(p string) string {
+	if filepath.IsAbs(p) {
+		if r, err := filepath.Rel(n.state.workTree, p); err == nil {
+			p = r
+		}
+	}
+
+	return path.Clean(filepath.ToSlash(p))
+}
+
+// location returns the location for a git time zone offset like +0100.
+func location(tz string) *time.Location {
+	if len(tz) != 5 {
+		return time.UTC
+	}
+
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:5])
+
+	if err1 != nil || err2 != nil {
+		return time.UTC
+	}
+
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+
+	return time.FixedZone(tz, offset)
+}
+
+// queueItem is a commit in the commit queue.
+type queueItem struct {
+	rev    string
+	commit *commitObject
+	seq    int
+}
+
+// commitQueue is a priority queue of commits ordered by commit date (newest
+// first). Commits with the same date are ordered by insertion.
+type commitQueue struct {
+	items []queueItem
+	seq   int
+}
+
+func (q commitQueue) Len() int { return len(q.items) }
+
+func (q commitQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if a.commit.committer.when != b.commit.committer.when {
+		return a.commit.committer.when > b.commit.committer.when
+	}
+
+	return a.seq < b.seq
+}
+
+func (q commitQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *commitQueue) Push(x interface{}) { q.items = append(q.items, x.(queueItem)) }
+
+func (q *commitQueue) Pop() interface{} {
+	item := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+
+	return item
+}
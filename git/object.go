@@ -0,0 +1,593 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// object types as used in pack files
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var objTypes = map[string]int{
+	"commit": objCommit,
+	"tree":   objTree,
+	"blob":   objBlob,
+	"tag":    objTag,
+}
+
+// errObjectNotFound is returned if an object does not exist.
+var errObjectNotFound = errors.New("object not found")
+
+// objectStore reads objects from the objects directory of a repository. Loose
+// objects and pack files (version 2 index) are supported.
+type objectStore struct {
+	dir   string
+	packs []*pack
+}
+
+func openObjectStore(dir string) (*objectStore, error) {
+	s := &objectStore{dir: dir}
+
+	idxFiles, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range idxFiles {
+		p, err := openPack(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pack '%s': %w", f, err)
+		}
+
+		s.packs = append(s.packs, p)
+	}
+
+	return s, nil
+}
+
+func (s *objectStore) close() error {
+	var err error
+
+	for _, p := range s.packs {
+		if e := p.f.Close(); e != nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// read returns the type and content of the object hash.
+func (s *objectStore) read(hash string) (int, []byte, error) {
+	typ, data, err := s.readLoose(hash)
+	if err == nil || !os.IsNotExist(err) {
+		return typ, data, err
+	}
+
+	for _, p := range s.packs {
+		offset, ok, err := p.find(hash)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if ok {
+			return p.read(s, offset)
+		}
+	}
+
+	return 0, nil, fmt.Errorf("%w: %s", errObjectNotFound, hash)
+}
+
+func (s *objectStore) readLoose(hash string) (int, []byte, error) {
+	if len(hash) != 40 {
+		return 0, nil, fmt.Errorf("invalid object name '%s'", hash)
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, hash[:2], hash[2:]))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close() // nolint: gosec
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// header: <type> <size>\0
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return 0, nil, fmt.Errorf("invalid object %s", hash)
+	}
+
+	fields := strings.Fields(string(b[:i]))
+	if len(fields) != 2 {
+		return 0, nil, fmt.Errorf("invalid object header of %s", hash)
+	}
+
+	typ, ok := objTypes[fields[0]]
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid object type '%s' of %s", fields[0], hash)
+	}
+
+	return typ, b[i+1:], nil
+}
+
+// maxCached is the maximum number of cached delta base objects per pack.
+const maxCached = 1024
+
+// pack is a pack file with its index.
+type pack struct {
+	f       *os.File
+	cache   map[int64]cachedObject // resolved delta base objects by offset
+	fanout  [256]uint32
+	names   []byte // sorted object names, 20 bytes each
+	offsets []byte // 4 bytes per object
+	large   []byte // 8 bytes per large offset
+}
+
+func openPack(idxFile string) (*pack, error) {
+	b, err := os.ReadFile(idxFile) // nolint: gosec
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < 8+256*4 || !bytes.Equal(b[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(b[4:8]) != 2 {
+		return nil, errors.New("unsupported pack index version")
+	}
+
+	f, err := os.Open(strings.TrimSuffix(idxFile, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pack{
+		f:     f,
+		cache: map[int64]cachedObject{},
+	}
+
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(b[8+i*4:])
+	}
+
+	n := int(p.fanout[255])
+	pos := 8 + 256*4
+
+	if len(b) < pos+n*(20+4+4) {
+		return nil, errors.New("truncated pack index")
+	}
+
+	p.names = b[pos : pos+n*20]
+	pos += n*20 + n*4 // skip crc32 values
+	p.offsets = b[pos : pos+n*4]
+	p.large = b[pos+n*4:]
+
+	return p, nil
+}
+
+// find returns the offset of the object hash in the pack file.
+func (p *pack) find(hash string) (int64, bool, error) {
+	name, err := hex.DecodeString(hash)
+	if err != nil || len(name) != 20 {
+		return 0, false, fmt.Errorf("invalid object name '%s'", hash)
+	}
+
+	lo := 0
+	if name[0] > 0 {
+		lo = int(p.fanout[name[0]-1])
+	}
+
+	hi := int(p.fanout[name[0]])
+
+	for lo < hi {
+		mid := (lo + hi) / 2
+
+		switch bytes.Compare(p.names[mid*20:mid*20+20], name) {
+		case 0:
+			return p.offset(mid)
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+
+	return 0, false, nil
+}
+
+func (p *pack) offset(i int) (int64, bool, error) {
+	o := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if o&0x80000000 == 0 {
+		return int64(o), true, nil
+	}
+
+	j := int(o & 0x7fffffff)
+	if len(p.large) < (j+1)*8 {
+		return 0, false, errors.New("invalid large offset in pack index")
+	}
+
+	return int64(binary.BigEndian.Uint64(p.large[j*8:])), true, nil
+}
+
+type cachedObject struct {
+	typ  int
+	data []byte
+}
+
+// base returns the object at offset used as delta base. Base objects are
+// cached, because they are often used by several deltas.
+func (p *pack) base(s *objectStore, offset int64) (int, []byte, error) {
+	if o, ok := p.cache[offset]; ok {
+		return o.typ, o.data, nil
+	}
+
+	typ, data, err := p.read(s, offset)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(p.cache) >= maxCached {
+		p.cache = map[int64]cachedObject{}
+	}
+
+	p.cache[offset] = cachedObject{typ: typ, data: data}
+
+	return typ, data, nil
+}
+
+// read returns the type and content of the object at offset. Deltas are
+// resolved.
+func (p *pack) read(s *objectStore, offset int64) (int, []byte, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.f, offset, 1<<62))
+
+	// header: type (3 bits) and size (variable length)
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	typ := int(c>>4) & 7
+
+	for c&0x80 != 0 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var (
+		baseType int
+		base     []byte
+	)
+
+	switch typ {
+	case objCommit, objTree, objBlob, objTag:
+		data, err := inflate(r)
+		return typ, data, err
+	case objOfsDelta:
+		rel, err := readOfsDeltaOffset(r)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		baseType, base, err = p.base(s, offset-rel)
+		if err != nil {
+			return 0, nil, err
+		}
+	case objRefDelta:
+		name := make([]byte, 20)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return 0, nil, err
+		}
+
+		baseType, base, err = s.read(hex.EncodeToString(name))
+		if err != nil {
+			return 0, nil, err
+		}
+	default:
+		return 0, nil, fmt.Errorf("unsupported object type %d in pack at offset %d", typ, offset)
+	}
+
+	delta, err := inflate(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	data, err := applyDelta(base, delta)
+
+	return baseType, data, err
+}
+
+func inflate(r io.Reader) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(zr)
+}
+
+// readOfsDeltaOffset reads the negative offset of the base object of an
+// offset delta.
+func readOfsDeltaOffset(r io.ByteReader) (int64, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	offset := int64(c & 0x7f)
+
+	for c&0x80 != 0 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+
+		offset = ((offset + 1) << 7) | int64(c&0x7f)
+	}
+
+	return offset, nil
+}
+
+// applyDelta applies a git delta to base.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errInvalid := errors.New("invalid delta")
+
+	readSize := func() (int, error) {
+		size, shift := 0, uint(0)
+
+		for {
+			if len(delta) == 0 {
+				return 0, errInvalid
+			}
+
+			c := delta[0]
+			delta = delta[1:]
+			size |= int(c&0x7f) << shift
+			shift += 7
+
+			if c&0x80 == 0 {
+				return size, nil
+			}
+		}
+	}
+
+	baseSize, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if baseSize != len(base) {
+		return nil, fmt.Errorf("invalid delta: base size %d does not match %d", baseSize, len(base))
+	}
+
+	size, err := readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, size)
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			// insert the next op bytes
+			n := int(op)
+			if n == 0 || n > len(delta) {
+				return nil, errInvalid
+			}
+
+			out = append(out, delta[:n]...)
+			delta = delta[n:]
+
+			continue
+		}
+
+		// copy from base: 4 offset and 3 size bytes, present if bit is set
+		var offset, n int
+
+		for i := uint(0); i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+
+			if len(delta) == 0 {
+				return nil, errInvalid
+			}
+
+			if i < 4 {
+				offset |= int(delta[0]) << (8 * i)
+			} else {
+				n |= int(delta[0]) << (8 * (i - 4))
+			}
+
+			delta = delta[1:]
+		}
+
+		if n == 0 {
+			n = 0x10000
+		}
+
+		if offset+n > len(base) {
+			return nil, errInvalid
+		}
+
+		out = append(out, base[offset:offset+n]...)
+	}
+
+	if len(out) != size {
+		return nil, fmt.Errorf("invalid delta: result size %d does not match %d", len(out), size)
+	}
+
+	return out, nil
+}
+
+// commitObject is a parsed commit object.
+type commitObject struct {
+	tree      string
+	parents   []string
+	author    signature
+	committer signature
+	message   string
+}
+
+// signature is the author or committer of a commit.
+type signature struct {
+	name  string
+	email string
+	when  int64
+	tz    string
+}
+
+func parseCommit(data []byte) (*commitObject, error) {
+	c := &commitObject{parents: []string{}}
+	headers, message := splitObject(data)
+	c.message = message
+
+	for _, h := range headers {
+		var err error
+
+		switch h[0] {
+		case "tree":
+			c.tree = h[1]
+		case "parent":
+			c.parents = append(c.parents, h[1])
+		case "author":
+			c.author, err = parseSignature(h[1])
+		case "committer":
+			c.committer, err = parseSignature(h[1])
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.tree == "" {
+		return nil, errors.New("invalid commit: no tree")
+	}
+
+	return c, nil
+}
+
+// parseTagObject returns the type and name of the object an annotated tag
+// points to.
+func parseTagObject(data []byte) (typ int, object string, err error) {
+	headers, _ := splitObject(data)
+
+	for _, h := range headers {
+		switch h[0] {
+		case "object":
+			object = h[1]
+		case "type":
+			typ = objTypes[h[1]]
+		}
+	}
+
+	if object == "" || typ == 0 {
+		return 0, "", errors.New("invalid tag object")
+	}
+
+	return typ, object, nil
+}
+
+// splitObject splits a commit or tag object into its headers and the message.
+// Continuation lines of multi-line headers (i.e: gpgsig) are ignored.
+func splitObject(data []byte) (headers [][2]string, message string) {
+	s := string(data)
+
+	for s != "" {
+		var line string
+
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			line, s = s, ""
+		} else {
+			line, s = s[:i], s[i+1:]
+		}
+
+		if line == "" {
+			return headers, s
+		}
+
+		if strings.HasPrefix(line, " ") {
+			continue
+		}
+
+		kv := strings.SplitN(line, " ", 2)
+		if len(kv) == 2 {
+			headers = append(headers, [2]string{kv[0], kv[1]})
+		}
+	}
+
+	return headers, ""
+}
+
+// parseSignature parses 'Name <email> 1609459200 +0100'.
+func parseSignature(s string) (signature, error) {
+	open, closing := strings.LastIndexByte(s, '<'), strings.LastIndexByte(s, '>')
+	if open < 0 || closing < open {
+		return signature{}, fmt.Errorf("invalid signature '%s'", s)
+	}
+
+	sig := signature{
+		name:  strings.TrimSpace(s[:open]),
+		email: s[open+1 : closing],
+	}
+
+	fields := strings.Fields(s[closing+1:])
+	if len(fields) != 2 {
+		return signature{}, fmt.Errorf("invalid signature '%s'", s)
+	}
+
+	when, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return signature{}, fmt.Errorf("invalid signature '%s': %w", s, err)
+	}
+
+	sig.when, sig.tz = when, fields[1]
+
+	return sig, nil
+}
+
+// treeEntry returns the object name of the entry name in a tree object.
+func treeEntry(data []byte, name string) (string, bool) {
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+
+		if sp < 0 || nul < sp || len(data) < nul+21 {
+			return "", false
+		}
+
+		if string(data[sp+1:nul]) == name {
+			return hex.EncodeToString(data[nul+1 : nul+21]), true
+		}
+
+		data = data[nul+21:]
+	}
+
+	return "", false
+}
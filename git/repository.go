@@ -0,0 +1,128 @@
+package git
+
+import "context"
+
+// Repository is a git repository with the operations needed to create
+// changelogs and releases. Command implements Repository with the git binary,
+// Memory is an in-memory implementation for tests.
+type Repository interface {
+	// IsRepo returns true if the current folder is a git repository.
+	IsRepo(ctx context.Context) bool
+	// TopLevelDir returns the top level directory of the working tree.
+	TopLevelDir(ctx context.Context) (string, error)
+	// HasRemote returns true if the remote name is configured.
+	HasRemote(ctx context.Context, name string) bool
+	// HasUncommitted returns true if there are uncommitted changes.
+	HasUncommitted(ctx context.Context) (bool, error)
+	// IsStaged returns true if path is tracked.
+	IsStaged(ctx context.Context, path string) bool
+
+	// WithTagFormat returns a copy of the repository with a different tag
+	// format.
+	WithTagFormat(f TagFormat) Repository
+	// WithSigning returns a copy of the repository that signs release
+	// commits and tags as configured by s.
+	WithSigning(s Signing) Repository
+	// TagName returns the name of the release tag for version.
+	TagName(version string) string
+	// ListTags returns all release tags sorted by semantic version in
+	// descending order.
+	ListTags(ctx context.Context) (Tags, error)
+	// MergedTags returns all release tags reachable from revision sorted by
+	// semantic version in descending order.
+	MergedTags(ctx context.Context, revision string) (Tags, error)
+	// HasTags returns true if the repository has release tags.
+	HasTags(ctx context.Context) (bool, error)
+	// TagDate returns the date (YYYY-MM-DD) of tag.
+	TagDate(ctx context.Context, tag string) (string, error)
+
+	// Log returns the commits start..end (newest first).
+	Log(ctx context.Context, start, end string, paths ...string) ([]Commit, error)
+
+	// StageFile stages file.
+	StageFile(ctx context.Context, file string) error
+	// CommitFiles commits files with message msg.
+	CommitFiles(ctx context.Context, msg string, files ...string) error
+	// CreateRelease creates the annotated release tag for version on HEAD
+	// with the message msg.
+	CreateRelease(ctx context.Context, version, msg string) error
+	// Push pushes commits and tags to a remote.
+	Push(ctx context.Context, opts PushOptions) error
+
+	// UnstageFile removes a newly staged file from the index.
+	UnstageFile(ctx context.Context, file string) error
+	// ChangedFiles returns the tracked files that differ from HEAD in the
+	// index or the working tree. The paths are relative to the top level
+	// directory.
+	ChangedFiles(ctx context.Context) ([]string, error)
+	// RestoreFile restores file in the index and the working tree from HEAD.
+	RestoreFile(ctx context.Context, file string) error
+	// ResetCommit removes the last commit and keeps its changes in the
+	// working tree.
+	ResetCommit(ctx context.Context) error
+	// DeleteRelease deletes the local release tag of version.
+	DeleteRelease(ctx context.Context, version string) error
+
+	// CurrentBranch returns the name of the current branch. For a detached
+	// HEAD an empty string is returned.
+	CurrentBranch(ctx context.Context) (string, error)
+	// SyncStatus compares the current branch with its upstream after fetching
+	// the remote.
+	SyncStatus(ctx context.Context) (SyncStatus, error)
+	// TagExists returns true if the tag name exists locally.
+	TagExists(ctx context.Context, name string) (bool, error)
+	// RemoteTagExists returns true if the tag name exists on the remote.
+	RemoteTagExists(ctx context.Context, remote, name string) (bool, error)
+	// RemoteBranchAt returns true if branch on the remote points at the
+	// local revision.
+	RemoteBranchAt(ctx context.Context, remote, branch, revision string) (bool, error)
+	// PointsAt returns the release tags pointing at revision.
+	PointsAt(ctx context.Context, revision string) (Tags, error)
+	// VerifyTag verifies the signature of the tag name.
+	VerifyTag(ctx context.Context, name string) error
+}
+
+// Signing configures the signing of release commits and tags. The signature
+// format (GPG or SSH) is configured with gpg.format in the git configuration.
+type Signing struct {
+	Commit bool   // sign the release commit
+	Tag    bool   // sign the release tag
+	Key    string // the signing key, if empty user.signingkey is used
+}
+
+// PushOptions configures a push.
+type PushOptions struct {
+	Remote string   // the remote to push to
+	Refs   []string // the refspecs to push, if empty the current branch is pushed with all reachable annotated tags
+	Atomic bool     // either all refs are updated on the remote or none
+}
+
+// SyncStatus is the state of the current branch compared to its upstream.
+type SyncStatus struct {
+	Upstream string // the upstream branch, empty if there is none
+	Ahead    int    // number of commits not in the upstream branch
+	Behind   int    // number of upstream commits not in the current branch
+}
+
+var (
+	_ Repository = Command{}
+	_ Repository = &Memory{}
+	_ Repository = &Native{}
+)
+
+// WithTagFormat returns a copy of c with the tag format f.
+func (c Command) WithTagFormat(f TagFormat) Repository {
+	c.TagFormat = f
+	return c
+}
+
+// WithSigning returns a copy of c with the signing configuration s.
+func (c Command) WithSigning(s Signing) Repository {
+	c.Signing = s
+	return c
+}
+
+// TagName returns the name of the release tag for version.
+func (c Command) TagName(version string) string {
+	return c.TagFormat.Name(version)
+}
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// TagFormat defines the name of release tags: [<component>/]<prefix>[v]<version>.
+type TagFormat struct {
+	Component string   // component name of monorepo tags (i.e: api leads to api/v1.2.0)
+	Prefix    string   // prefix before the version (i.e: release- leads to release-v1.2.0)
+	OmitV     bool     // if true, no 'v' is added before the version
+	Include   []string // if set, only tags matching one of these glob patterns are release tags
+	Exclude   []string // tags matching one of these glob patterns are no release tags
+}
+
+// Name returns the tag name for version.
+func (f TagFormat) Name(version string) string {
+	if f.OmitV {
+		return f.prefix() + version
+	}
+
+	return f.prefix() + "v" + version
+}
+
+// Parse returns the tag for name. If name does not match the tag format or
+// the version is not a semantic version, ok is false. The 'v' before the
+// version is always optional.
+func (f TagFormat) Parse(name string) (tag Tag, ok bool) {
+	if !strings.HasPrefix(name, f.prefix()) {
+		return Tag{}, false
+	}
+
+	v := strings.TrimPrefix(strings.TrimPrefix(name, f.prefix()), "v")
+
+	// only the version may follow the prefix
+	if v == "" || v[0] < '0' || v[0] > '9' {
+		return Tag{}, false
+	}
+
+	version, err := semver.NewVersion(v)
+	if err != nil {
+		return Tag{}, false
+	}
+
+	return Tag{
+		Name:    name,
+		Version: version,
+	}, true
+}
+
+func (f TagFormat) prefix() string {
+	if f.Component == "" {
+		return f.Prefix
+	}
+
+	return f.Component + "/" + f.Prefix
+}
+
+// pattern returns the glob pattern to list tags.
+func (f TagFormat) pattern() string {
+	return f.prefix() + "*"
+}
+
+// skippedTag is a tag that is no release tag.
+type skippedTag struct {
+	name   string
+	reason string
+}
+
+// parse parses the output of git tag. Tags that do not match the tag format
+// or the include and exclude patterns are returned as skipped tags.
+func (f TagFormat) parse(out string) (Tags, []skippedTag) {
+	tags := Tags{}
+	skipped := []skippedTag{}
+
+	for _, name := range strings.Split(strings.TrimSpace(out), "\n") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if len(f.Include) > 0 && !match(f.Include, name) {
+			skipped = append(skipped, skippedTag{name: name, reason: "not included"})
+			continue
+		}
+
+		if match(f.Exclude, name) {
+			skipped = append(skipped, skippedTag{name: name, reason: "excluded"})
+			continue
+		}
+
+		t, ok := f.Parse(name)
+		if !ok {
+			skipped = append(skipped, skippedTag{name: name, reason: "no semantic version"})
+			continue
+		}
+
+		tags = append(tags, t)
+	}
+
+	tags.sort()
+
+	return tags, skipped
+}
+
+// match returns true if name matches one of the glob patterns.
+func match(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tag is a release tag.
+type Tag struct {
+	Name    string
+	Version *semver.Version
+}
+
+// IsPrerelease returns true if the version of the tag has a pre-release
+// identifier.
+func (t Tag) IsPrerelease() bool {
+	return t.Version.Prerelease() != ""
+}
+
+// Tags is a slice of tags.
+type Tags []Tag
+
+// Index returns the index of name. If not found -1 is returned.
+func (t Tags) Index(name string) int {
+	for i := range t {
+		if t[i].Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// sort sorts tags by semantic version in descending order. Pre-release
+// versions are lower than the corresponding release version.
+func (t Tags) sort() {
+	sort.SliceStable(t, func(i, j int) bool {
+		return t[i].Version.GreaterThan(t[j].Version)
+	})
+}
@@ -20,7 +20,8 @@ type ReleaseType int
 
 // All possible release types.
 const (
-	Patch ReleaseType = iota + 1
+	None ReleaseType = iota
+	Patch
 	Minor
 	Major
 )
@@ -30,6 +31,7 @@ type Changelog struct {
 	typeSections typeSections
 	cfg          config.Changelog
 	releaseType  ReleaseType
+	count        int
 	logFunc      func(msg string, keysAndValues ...interface{})
 }
 
@@ -37,7 +39,7 @@ type Changelog struct {
 func New(opts ...Option) (*Changelog, error) {
 	c := Changelog{
 		typeSections: typeSections{},
-		releaseType:  Patch,
+		releaseType:  None,
 	}
 
 	for _, opt := range opts {
@@ -53,7 +55,8 @@ func New(opts ...Option) (*Changelog, error) {
 	return &c, nil
 }
 
-// AddMessage add a new commit message to the changelog.
+// AddMessage add a new commit message to the changelog. Messages have to be
+// added in the order of git rev-list (newest first).
 func (c *Changelog) AddMessage(hash, message string) error {
 	if c.logFunc != nil {
 		c.logFunc("parsing message",
@@ -67,12 +70,23 @@ func (c *Changelog) AddMessage(hash, message string) error {
 		return fmt.Errorf("unconventional commit detected - failed to parse '%s': %w", message, err)
 	}
 
-	if co.Header.Scope == "" {
-		co.Header.Scope = "common"
+	if !c.cfg.IsAllowedScope(co.Header.Scope) {
+		return fmt.Errorf("scope '%s' of '%s' is not allowed", co.Header.Scope, co.Header.Description)
 	}
 
-	if co.Header.Type == "feat" && c.releaseType != Major {
-		c.releaseType = Minor
+	noScope := co.Header.Scope == ""
+	if noScope {
+		co.Header.Scope = c.cfg.Localized().Scope
+	}
+
+	if title, ok := c.cfg.ScopeTitle(co.Header.Scope); ok {
+		co.Header.Scope = title
+	}
+
+	co.Header.Type = c.cfg.Type(co.Header.Type)
+
+	if rt := releaseTypeOf(c.cfg.Bump(co.Header.Type)); rt > c.releaseType {
+		c.releaseType = rt
 	}
 
 	title, ok := c.cfg.Title(co.Header.Type)
@@ -83,10 +97,15 @@ func (c *Changelog) AddMessage(hash, message string) error {
 	commit := Commit{
 		revisionURL: c.revisionURL(hash),
 		Commit:      *co,
+		index:       c.count,
+		noScope:     noScope,
 	}
 
+	c.count++
+
 	issueNr, ok := closedIssue(*co)
 	if ok {
+		commit.issue = issueNr
 		commit.issueURL = c.issueURL(issueNr)
 	}
 
@@ -104,7 +123,7 @@ func (c *Changelog) AddMessage(hash, message string) error {
 	breakingMessage := co.BreakingMessage()
 	if breakingMessage != "" {
 		c.releaseType = Major
-		title = "Breaking Changes"
+		title = c.cfg.BreakingTitle()
 		c.typeSections.add(title, commit)
 
 		return nil
@@ -118,13 +137,20 @@ func (c *Changelog) AddMessage(hash, message string) error {
 func (c *Changelog) Write(title string, w io.Writer) {
 	w.Write([]byte(heading(2, title)))
 
-	for _, title := range c.cfg.List() {
-		s, ok := c.typeSections[title]
-		if !ok || len(s.scopeSections) == 0 {
-			continue
-		}
+	switch c.cfg.Layout {
+	case config.LayoutType:
+		c.writeByType(w)
+	case config.LayoutScopeType:
+		c.writeByScope(w)
+	default:
+		for _, title := range c.cfg.List() {
+			s, ok := c.typeSections[title]
+			if !ok || len(s.scopeSections) == 0 {
+				continue
+			}
 
-		s.write(w)
+			s.write(w, c.cfg.CommitOrder)
+		}
 	}
 
 	w.Write([]byte(nl + nl + nl))
@@ -136,15 +162,34 @@ func (c *Changelog) Write(title string, w io.Writer) {
 // BREAKING CHANGE: -> Major release
 // feat:            -> Minor release
 // all other:       -> Patch release
+//
+// The bump of each type can be configured. If no commit leads to
+// a version increase, None is returned.
 func (c *Changelog) ReleaseType() ReleaseType {
 	return c.releaseType
 }
 
+func releaseTypeOf(bump string) ReleaseType {
+	switch bump {
+	case config.BumpMajor:
+		return Major
+	case config.BumpMinor:
+		return Minor
+	case config.BumpNone:
+		return None
+	default:
+		return Patch
+	}
+}
+
 // Commit represents a commit.
 type Commit struct {
 	cc.Commit
 	revisionURL string
 	issueURL    string
+	issue       int
+	index       int  // position in git history, 0 is the newest commit
+	noScope     bool // true if the commit message has no scope
 }
 
 type typeSections map[string]typeSection
@@ -165,6 +210,17 @@ type typeSection struct {
 	scopeSections scopeSections
 }
 
+// commits returns the commits of all scopes.
+func (s typeSection) commits() []Commit {
+	r := []Commit{}
+
+	for _, scope := range s.scopeSections {
+		r = append(r, scope.commits...)
+	}
+
+	return r
+}
+
 type scopeSections map[string]*scopeSection
 
 func (s scopeSections) add(c Commit) {
@@ -201,6 +257,31 @@ func (s *scopeSection) add(c Commit) {
 	s.commits = append(s.commits, c)
 }
 
+// sortCommits sorts commits according to the configured commit order.
+func sortCommits(commits []Commit, order string) {
+	sort.SliceStable(commits, func(i, j int) bool {
+		switch order {
+		case config.CommitOrderChronological:
+			return commits[i].index > commits[j].index
+		case config.CommitOrderReverseChronological:
+			return commits[i].index < commits[j].index
+		case config.CommitOrderIssue:
+			if commits[i].issue != commits[j].issue {
+				// commits without issue are listed last
+				return commits[j].issue == 0 || (commits[i].issue != 0 && commits[i].issue < commits[j].issue)
+			}
+
+			return commits[i].index > commits[j].index
+		default:
+			if commits[i].Header.Description == commits[j].Header.Description {
+				return commits[i].Header.Scope < commits[j].Header.Scope
+			}
+
+			return commits[i].Header.Description < commits[j].Header.Description
+		}
+	})
+}
+
 var issueRegexp = regexp.MustCompile(`#(\d+)`)
 
 func closedIssue(c cc.Commit) (issueNR int, ok bool) {
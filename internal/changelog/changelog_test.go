@@ -10,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zbindenren/cc/config"
 	"gopkg.in/yaml.v3"
 )
 
@@ -127,6 +128,236 @@ func TestWrite(t *testing.T) {
 	})
 }
 
+// nolint: funlen
+func TestWriteLayout(t *testing.T) {
+	var tt = []struct {
+		layout   string
+		expected string
+	}{
+		{
+			config.LayoutType,
+			`## title
+
+
+### Breaking Changes
+
+* **router**: add a breaking change (#12345, 00000008)
+  > this is the body of the breaking change
+  > breaks all
+* breaking change (00000006)
+* **router**: breaking change again (00000007)
+
+
+### Bug Fixes
+
+* a fix (00000001)
+  > this is the body
+* **router**: another fix (#1, 00000003)
+* fixed this (00000002)
+
+
+### New Features
+
+* a feature (00000004)
+
+
+
+`,
+		},
+		{
+			config.LayoutScopeType,
+			`## title
+
+
+### common
+
+#### Breaking Changes
+
+* breaking change (00000006)
+
+#### Bug Fixes
+
+* a fix (00000001)
+  > this is the body
+* fixed this (00000002)
+
+#### New Features
+
+* a feature (00000004)
+
+
+### router
+
+#### Breaking Changes
+
+* add a breaking change (#12345, 00000008)
+  > this is the body of the breaking change
+  > breaks all
+* breaking change again (00000007)
+
+#### Bug Fixes
+
+* another fix (#1, 00000003)
+
+
+
+`,
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.layout, func(t *testing.T) {
+			cfg := config.Default
+			cfg.Layout = tc.layout
+
+			c, err := New(WithConfig(cfg))
+			require.NoError(t, err)
+
+			for _, m := range messagesFrom(t, "test-messages.yml") {
+				err := c.AddMessage(m.Commit, m.Message)
+				require.NoError(t, err)
+			}
+
+			b := bytes.NewBufferString("")
+			c.Write("title", b)
+
+			expected := tc.expected
+			if runtime.GOOS == windowsOS {
+				expected = strings.ReplaceAll(expected, "\n", "\r\n")
+			}
+
+			assert.Equal(t, expected, b.String())
+		})
+	}
+}
+
+func TestWriteLocalized(t *testing.T) {
+	cfg := config.Default
+	cfg.Language = "de"
+	cfg.GithubProjectPath = "zbindenren/cc"
+
+	c, err := New(WithConfig(cfg))
+	require.NoError(t, err)
+
+	require.NoError(t, c.AddMessage("00000001aa", "fix: a fix"))
+	require.NoError(t, c.AddMessage("00000002aa", "feat!: remove option"))
+
+	expected := `## title
+
+
+### Inkompatible Änderungen
+
+* **allgemein**
+  * **[00000002](https://github.com/zbindenren/cc/commit/00000002)**:
+    remove option
+
+
+### Bug Fixes
+
+* **allgemein**: a fix ([00000001](https://github.com/zbindenren/cc/commit/00000001))
+
+
+
+`
+
+	b := bytes.NewBufferString("")
+	c.Write("title", b)
+
+	if runtime.GOOS == windowsOS {
+		expected = strings.ReplaceAll(expected, "\n", "\r\n")
+	}
+
+	assert.Equal(t, expected, b.String())
+}
+
+func TestScopes(t *testing.T) {
+	cfg := config.Default
+	cfg.Scopes = []config.Scope{
+		{Name: "web", Title: "Web UI", Aliases: []string{"ui", "frontend"}},
+	}
+
+	c, err := New(WithConfig(cfg))
+	require.NoError(t, err)
+
+	require.NoError(t, c.AddMessage("00000001", "fix(ui): a fix"))
+	require.NoError(t, c.AddMessage("00000002", "fix(frontend): another fix"))
+	require.NoError(t, c.AddMessage("00000003", "fix(api): an api fix"))
+
+	scopes := c.typeSections["Bug Fixes"].scopeSections
+	assert.Len(t, scopes["Web UI"].commits, 2)
+	assert.Len(t, scopes["api"].commits, 1)
+
+	cfg.RestrictScopes = true
+
+	c, err = New(WithConfig(cfg))
+	require.NoError(t, err)
+
+	require.NoError(t, c.AddMessage("00000001", "fix(ui): a fix"))
+	require.NoError(t, c.AddMessage("00000002", "fix: a fix without scope"))
+	require.Error(t, c.AddMessage("00000003", "fix(api): an api fix"))
+}
+
+func TestSortCommits(t *testing.T) {
+	// in git rev-list order: newest first
+	messages := []string{
+		"fix: b fix",
+		"fix: c fix\n\nCloses: #2",
+		"fix: a fix",
+		"fix: d fix\n\nCloses: #1",
+	}
+
+	var tt = []struct {
+		order    string
+		expected []string
+	}{
+		{
+			"",
+			[]string{"a fix", "b fix", "c fix", "d fix"},
+		},
+		{
+			config.CommitOrderAlphabetical,
+			[]string{"a fix", "b fix", "c fix", "d fix"},
+		},
+		{
+			config.CommitOrderChronological,
+			[]string{"d fix", "a fix", "c fix", "b fix"},
+		},
+		{
+			config.CommitOrderReverseChronological,
+			[]string{"b fix", "c fix", "a fix", "d fix"},
+		},
+		{
+			config.CommitOrderIssue,
+			[]string{"d fix", "c fix", "a fix", "b fix"},
+		},
+	}
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.order, func(t *testing.T) {
+			c, err := New()
+			require.NoError(t, err)
+
+			for _, m := range messages {
+				require.NoError(t, c.AddMessage("00000001", m))
+			}
+
+			commits := c.typeSections["Bug Fixes"].scopeSections["common"].commits
+			sortCommits(commits, tc.order)
+
+			descriptions := make([]string, 0, len(commits))
+			for _, commit := range commits {
+				descriptions = append(descriptions, commit.Header.Description)
+			}
+
+			assert.Equal(t, tc.expected, descriptions)
+		})
+	}
+}
+
 func TestReleaseType(t *testing.T) {
 	var tt = []struct {
 		name     string
@@ -171,6 +402,68 @@ func TestReleaseType(t *testing.T) {
 	}
 }
 
+func TestReleaseTypeConfig(t *testing.T) {
+	var tt = []struct {
+		name     string
+		messages []string
+		expected ReleaseType
+	}{
+		{
+			"minor - type alias",
+			[]string{"chore: chore", "feature: feat"},
+			Minor,
+		},
+		{
+			"minor - case insensitive type",
+			[]string{"chore: chore", "FEAT: feat"},
+			Minor,
+		},
+		{
+			"minor - case insensitive type alias",
+			[]string{"chore: chore", "Feature: feat"},
+			Minor,
+		},
+		{
+			"minor - configured bump",
+			[]string{"fix: fix", "perf: perf"},
+			Minor,
+		},
+		{
+			"none",
+			[]string{"docs: docs", "test: test"},
+			None,
+		},
+		{
+			"none - no messages",
+			[]string{},
+			None,
+		},
+	}
+
+	cfg := config.Default
+	cfg.CaseInsensitiveTypes = true
+	cfg.Sections = append([]config.Section{}, config.Default.Sections...)
+	cfg.Sections[2].Aliases = []string{"feature"}
+	cfg.Sections[1].Bump = config.BumpNone // docs
+	cfg.Sections[5].Bump = config.BumpNone // test
+	cfg.Sections = append(cfg.Sections, config.Section{Type: "perf", Title: "Performance", Bump: config.BumpMinor})
+
+	for i := range tt {
+		tc := tt[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			cw, err := New(WithConfig(cfg))
+			require.NoError(t, err)
+
+			for _, m := range tc.messages {
+				err := cw.AddMessage("aff5b0e55c1ede1c33425568f842e908f97eff89", m)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, cw.ReleaseType())
+		})
+	}
+}
+
 type message struct {
 	Commit  string
 	Message string
@@ -19,7 +19,70 @@ const (
 	githubIssuesPath = "/issues"
 )
 
-func (s typeSection) write(w io.Writer) {
+// writeByType writes all commits of a type as flat list.
+func (c *Changelog) writeByType(w io.Writer) {
+	for _, title := range c.cfg.List() {
+		s, ok := c.typeSections[title]
+		if !ok || len(s.scopeSections) == 0 {
+			continue
+		}
+
+		s.writeHeading(w)
+
+		commits := s.commits()
+		sortCommits(commits, c.cfg.CommitOrder)
+
+		for i := range commits {
+			commits[i].writeItem(w, !commits[i].noScope)
+		}
+	}
+}
+
+// writeByScope groups commits by scope and then by type.
+func (c *Changelog) writeByScope(w io.Writer) {
+	titles := c.cfg.List()
+	names := []string{}
+
+	for _, title := range titles {
+		for name := range c.typeSections[title].scopeSections {
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		w.Write([]byte(nl + nl + heading(3, name)))
+
+		for _, title := range titles {
+			scope, ok := c.typeSections[title].scopeSections[name]
+			if !ok {
+				continue
+			}
+
+			w.Write([]byte(nl + heading(4, title) + nl))
+
+			commits := scope.commits
+			sortCommits(commits, c.cfg.CommitOrder)
+
+			for i := range commits {
+				commits[i].writeItem(w, false)
+			}
+		}
+	}
+}
+
+func (s typeSection) write(w io.Writer, order string) {
+	s.writeHeading(w)
+
+	for _, scope := range s.scopeSections.list() {
+		scope.write(w, order)
+	}
+}
+
+func (s typeSection) writeHeading(w io.Writer) {
 	var sb strings.Builder
 
 	sb.WriteString(nl)
@@ -28,23 +91,13 @@ func (s typeSection) write(w io.Writer) {
 	sb.WriteString(nl)
 
 	w.Write([]byte(sb.String()))
-
-	for _, scope := range s.scopeSections.list() {
-		scope.write(w)
-	}
 }
 
-func (s scopeSection) write(w io.Writer) {
+func (s scopeSection) write(w io.Writer, order string) {
 	var b strings.Builder
 
 	commits := s.commits
-	sort.Slice(commits, func(i, j int) bool {
-		if commits[i].Header.Scope == commits[j].Header.Scope {
-			return commits[i].Header.Description < commits[j].Header.Description
-		}
-
-		return commits[i].Header.Scope < commits[j].Header.Scope
-	})
+	sortCommits(commits, order)
 
 	for i := range commits {
 		if i == 0 && commits[i].BreakingMessage() != "" {
@@ -62,7 +115,7 @@ func (c *Commit) write(w io.Writer) {
 		return
 	}
 
-	c.writeStandard(w)
+	c.writeItem(w, true)
 }
 
 func (c *Commit) writeBreaking(w io.Writer) {
@@ -97,7 +150,9 @@ func (c *Commit) writeBreaking(w io.Writer) {
 	w.Write([]byte(l))
 }
 
-func (c *Commit) writeStandard(w io.Writer) {
+// writeItem writes the commit as a single list item. The scope is only
+// written if withScope is true.
+func (c *Commit) writeItem(w io.Writer, withScope bool) {
 	var s strings.Builder
 
 	urls := []string{c.revisionURL}
@@ -105,18 +160,33 @@ func (c *Commit) writeStandard(w io.Writer) {
 		urls = append([]string{c.issueURL}, urls...)
 	}
 
-	s.WriteString(bold(c.Header.Scope))
-	s.WriteString(": ")
+	if withScope {
+		s.WriteString(bold(c.Header.Scope))
+		s.WriteString(": ")
+	}
+
 	s.WriteString(c.Header.Description)
 	s.WriteString(" (")
 	s.WriteString(strings.Join(urls, ", "))
 	s.WriteString(")")
 
-	if c.Body != "" {
+	breakingMsg := c.BreakingMessage()
+	if breakingMsg == c.Header.Description {
+		breakingMsg = ""
+	}
+
+	if c.Body != "" || breakingMsg != "" {
 		s.WriteString(nl)
+	}
+
+	if c.Body != "" {
 		s.WriteString(blockQuote(0, c.Body))
 	}
 
+	if breakingMsg != "" {
+		s.WriteString(blockQuote(0, breakingMsg))
+	}
+
 	l := listItem(1, s.String())
 	w.Write([]byte(l))
 }
@@ -205,6 +275,16 @@ func listItem(level int, data string) string {
 	return s.String()
 }
 
+func contains(l []string, s string) bool {
+	for i := range l {
+		if l[i] == s {
+			return true
+		}
+	}
+
+	return false
+}
+
 func bold(data string) string {
 	var s strings.Builder
 